@@ -0,0 +1,153 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// A Cluster is a group of servers that share the work of hosting databases.
+// Spreading databases across several servers avoids the limits
+// of a single server process, like max_connections,
+// in large parallel test suites.
+type Cluster struct {
+	servers []*Server
+	next    uint32 // accessed atomically
+
+	mu     sync.Mutex
+	owners map[string]*Server // keyed by data source name
+}
+
+// StartCluster starts n servers with the given options
+// and waits for them to accept connections.
+// Servers are started concurrently, at most runtime.GOMAXPROCS(0) at a time.
+// If any server fails to start, StartCluster stops the others
+// and returns the first error.
+func StartCluster(ctx context.Context, n int, opts ...StartOption) (*Cluster, error) {
+	if n < 1 {
+		return nil, errors.New("start cluster: need at least one server")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	servers := make([]*Server, n)
+	errs := make([]error, n)
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i := range servers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			servers[i], errs[i] = Start(ctx, opts...)
+			if errs[i] != nil {
+				// Stop starting the other servers.
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	c := &Cluster{owners: make(map[string]*Server)}
+	var firstErr error
+	for i, srv := range servers {
+		if srv != nil {
+			c.servers = append(c.servers, srv)
+		}
+		// Prefer an error that caused the cancellation
+		// over the cancellations it caused.
+		if err := errs[i]; err != nil && (firstErr == nil || errors.Is(firstErr, context.Canceled)) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		c.Cleanup()
+		return nil, fmt.Errorf("start cluster: %w", firstErr)
+	}
+	return c, nil
+}
+
+// Servers returns the servers in the cluster.
+// The caller must not modify the returned slice.
+func (c *Cluster) Servers() []*Server {
+	return c.servers
+}
+
+// CreateDatabase creates a new database on the next server in the cluster
+// and returns its data source name. Servers are chosen in round-robin order.
+func (c *Cluster) CreateDatabase(ctx context.Context, opts ...DatabaseOption) (string, error) {
+	srv := c.nextServer()
+	dsn, err := srv.CreateDatabase(ctx, opts...)
+	if err != nil {
+		return "", err
+	}
+	c.setOwner(dsn, srv)
+	return dsn, nil
+}
+
+// NewDatabase opens a connection to a freshly created database
+// on the next server in the cluster, as Server.NewDatabase does.
+// The returned *sql.DB is closed by Cleanup if it is still open.
+func (c *Cluster) NewDatabase(ctx context.Context, opts ...DatabaseOption) (*sql.DB, error) {
+	srv := c.nextServer()
+	db, dbName, err := srv.newDatabase(ctx, newDatabaseConfig(opts))
+	if err != nil {
+		return nil, fmt.Errorf("new database: %w", err)
+	}
+	c.setOwner(srv.DSN(dbName), srv)
+	return db, nil
+}
+
+// nextServer returns the next server in round-robin order.
+func (c *Cluster) nextServer() *Server {
+	i := atomic.AddUint32(&c.next, 1) - 1
+	return c.servers[int(i%uint32(len(c.servers)))]
+}
+
+func (c *Cluster) setOwner(dsn string, srv *Server) {
+	c.mu.Lock()
+	c.owners[dsn] = srv
+	c.mu.Unlock()
+}
+
+// Owner returns the server that hosts the database
+// with the given data source name, or nil if the database
+// was not created by c.CreateDatabase or c.NewDatabase.
+func (c *Cluster) Owner(dsn string) *Server {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.owners[dsn]
+}
+
+// Cleanup shuts down all the servers in the cluster
+// and deletes any on-disk files the servers used.
+func (c *Cluster) Cleanup() {
+	for _, srv := range c.servers {
+		srv.Cleanup()
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestCluster(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*singleTestTime)
+	defer cancel()
+	c, err := StartCluster(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Cleanup)
+
+	owners := make(map[*Server]int)
+	for i := 0; i < 4; i++ {
+		dsn, err := c.CreateDatabase(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := c.Owner(dsn)
+		if srv == nil {
+			t.Fatalf("c.Owner(%q) = <nil>", dsn)
+		}
+		owners[srv]++
+	}
+	for _, srv := range c.Servers() {
+		if owners[srv] != 2 {
+			t.Errorf("Server in %s owns %d databases; want 2", srv.dir, owners[srv])
+		}
+	}
+}
+
+func BenchmarkClusterCreateDatabase(b *testing.B) {
+	for _, n := range []int{1, 4} {
+		b.Run(fmt.Sprintf("Servers=%d", n), func(b *testing.B) {
+			ctx := context.Background()
+			c, err := StartCluster(ctx, n)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Cleanup(c.Cleanup)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := c.CreateDatabase(ctx); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkStartCluster(b *testing.B) {
+	const n = 4
+	b.Run("Sequential", func(b *testing.B) {
+		ctx := context.Background()
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < n; j++ {
+				srv, err := Start(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				srv.Cleanup()
+			}
+		}
+	})
+	b.Run("StartCluster", func(b *testing.B) {
+		ctx := context.Background()
+		for i := 0; i < b.N; i++ {
+			c, err := StartCluster(ctx, n)
+			if err != nil {
+				b.Fatal(err)
+			}
+			c.Cleanup()
+		}
+	})
+}
@@ -0,0 +1,204 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// CopyFrom loads rows into the given table of the database with the given
+// data source name using the COPY protocol, which is much faster than
+// inserting rows one at a time. Each row must have one value per column.
+// All rows are loaded in a single transaction.
+// CopyFrom always connects using github.com/lib/pq,
+// regardless of the driver set by WithDriverName.
+func (srv *Server) CopyFrom(ctx context.Context, dsn, table string, columns []string, rows [][]interface{}) (err error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return fmt.Errorf("copy into %s: %w", table, err)
+	}
+	defer db.Close()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("copy into %s: %w", table, err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return fmt.Errorf("copy into %s: %w", table, err)
+	}
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			stmt.Close()
+			return fmt.Errorf("copy into %s: row %d has %d values for %d columns", table, i, len(row), len(columns))
+		}
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			stmt.Close()
+			return fmt.Errorf("copy into %s: %w", table, err)
+		}
+	}
+	// Executing the statement with no arguments flushes the buffered rows.
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return fmt.Errorf("copy into %s: %w", table, err)
+	}
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("copy into %s: %w", table, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("copy into %s: %w", table, err)
+	}
+	return nil
+}
+
+// CopyOptions holds optional parameters for CopyCSV.
+type CopyOptions struct {
+	// Columns lists the table columns that the CSV's fields are loaded into,
+	// in order. If empty, the CSV must have a field for every column.
+	Columns []string
+	// Header indicates that the CSV's first line is a header to skip.
+	Header bool
+	// Delimiter separates fields. If empty, a comma is used.
+	Delimiter string
+	// Null is the string that represents a NULL value.
+	// If empty, an unquoted empty field is NULL.
+	Null string
+}
+
+// statement returns a COPY ... FROM STDIN statement for loading CSV
+// into the given table.
+func (opts CopyOptions) statement(table string) string {
+	sb := new(strings.Builder)
+	sb.WriteString("COPY ")
+	sb.WriteString(quoteIdentifier(table))
+	if len(opts.Columns) > 0 {
+		sb.WriteString(" (")
+		for i, col := range opts.Columns {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(quoteIdentifier(col))
+		}
+		sb.WriteString(")")
+	}
+	sb.WriteString(" FROM STDIN WITH (FORMAT csv")
+	if opts.Header {
+		sb.WriteString(", HEADER true")
+	}
+	if opts.Delimiter != "" {
+		sb.WriteString(", DELIMITER ")
+		sb.WriteString(quoteLiteral(opts.Delimiter))
+	}
+	if opts.Null != "" {
+		sb.WriteString(", NULL ")
+		sb.WriteString(quoteLiteral(opts.Null))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// CopyCSV loads the CSV read from csv into the given table of the database
+// with the given data source name by streaming it through COPY ... FROM STDIN,
+// which is much faster than inserting rows one at a time.
+// The zero CopyOptions reads comma-separated values without a header.
+// All rows are loaded in a single transaction.
+// CopyCSV always connects using github.com/lib/pq,
+// regardless of the driver set by WithDriverName.
+func (srv *Server) CopyCSV(ctx context.Context, dsn, table string, csv io.Reader, opts CopyOptions) (err error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return fmt.Errorf("copy csv into %s: %w", table, err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("copy csv into %s: %w", table, err)
+	}
+	defer conn.Close()
+	// lib/pq only allows COPY inside a transaction.
+	if _, err := conn.ExecContext(ctx, "BEGIN;"); err != nil {
+		return fmt.Errorf("copy csv into %s: %w", table, err)
+	}
+	defer func() {
+		if err != nil {
+			conn.ExecContext(context.Background(), "ROLLBACK;")
+		}
+	}()
+	err = conn.Raw(func(driverConn interface{}) error {
+		return copyCSV(ctx, driverConn.(driver.Conn), opts.statement(table), csv)
+	})
+	if err != nil {
+		return fmt.Errorf("copy csv into %s: %w", table, err)
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT;"); err != nil {
+		return fmt.Errorf("copy csv into %s: %w", table, err)
+	}
+	return nil
+}
+
+// copyCSV runs the given COPY ... FROM STDIN statement on a lib/pq connection,
+// sending the contents of r line by line.
+func copyCSV(ctx context.Context, conn driver.Conn, query string, r io.Reader) error {
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	copier, ok := stmt.(interface {
+		CopyData(ctx context.Context, line string) (driver.Result, error)
+	})
+	if !ok {
+		return errors.New("driver does not support COPY FROM STDIN")
+	}
+	br := bufio.NewReader(r)
+	for {
+		// CopyData appends a newline to each line.
+		// Splitting on newlines and letting CopyData add them back
+		// leaves quoted fields that span lines intact.
+		line, err := br.ReadString('\n')
+		if line != "" {
+			if _, err := copier.CopyData(ctx, strings.TrimSuffix(line, "\n")); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// Executing the statement with no arguments ends the COPY
+	// and reports any errors from the data.
+	if _, err := stmt.Exec(nil); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCopyFrom(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `CREATE TABLE foo (id INTEGER PRIMARY KEY, name TEXT);`); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 1000
+	rows := make([][]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		rows = append(rows, []interface{}{i, "xyzzy"})
+	}
+	if err := srv.CopyFrom(ctx, dsn, "foo", []string{"id", "name"}, rows); err != nil {
+		t.Fatal(err)
+	}
+	var got int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM foo WHERE name = 'xyzzy';`).Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != n {
+		t.Errorf("foo has %d rows; want %d", got, n)
+	}
+}
+
+func TestCopyCSV(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `CREATE TABLE foo (id INTEGER PRIMARY KEY, name TEXT);`); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 1000
+	csv := new(strings.Builder)
+	csv.WriteString("name;id\n")
+	for i := 0; i < n; i++ {
+		switch i {
+		case 0:
+			fmt.Fprintf(csv, "\"multi\nline\";%d\n", i)
+		case 1:
+			fmt.Fprintf(csv, "NULL;%d\n", i)
+		default:
+			fmt.Fprintf(csv, "xyzzy;%d\n", i)
+		}
+	}
+	err = srv.CopyCSV(ctx, dsn, "foo", strings.NewReader(csv.String()), CopyOptions{
+		Columns:   []string{"name", "id"},
+		Header:    true,
+		Delimiter: ";",
+		Null:      "NULL",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM foo;`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != n {
+		t.Errorf("foo has %d rows; want %d", count, n)
+	}
+	var name sql.NullString
+	if err := db.QueryRowContext(ctx, `SELECT name FROM foo WHERE id = 0;`).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if want := "multi\nline"; name.String != want {
+		t.Errorf("name of row 0 = %q; want %q", name.String, want)
+	}
+	if err := db.QueryRowContext(ctx, `SELECT name FROM foo WHERE id = 1;`).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name.Valid {
+		t.Errorf("name of row 1 = %q; want NULL", name.String)
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		err := srv.CopyCSV(ctx, dsn, "foo", strings.NewReader("not a number,xyzzy\n"), CopyOptions{})
+		if err == nil {
+			t.Error("CopyCSV with invalid data did not return an error")
+		}
+	})
+}
@@ -0,0 +1,88 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backtraceTimeout is the longest time spent running gdb
+// to get a backtrace from a single core file.
+const backtraceTimeout = 30 * time.Second
+
+// CoreDumps returns the paths of the core files
+// that crashed server processes have left in the data directory.
+// Core files are only written if the server was started with WithCoreDumps
+// and the operating system writes core files to the crashed process's
+// working directory, which on Linux depends on kernel.core_pattern.
+// Cleanup removes the core files along with the data directory
+// unless WithPreserveOnFailure keeps them.
+func (srv *Server) CoreDumps() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(srv.dataDir, "core*"))
+	if err != nil {
+		return nil, fmt.Errorf("find core dumps: %w", err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// describeCoreDumps returns a description of the core files
+// in the data directory, including backtraces if gdb is available,
+// or the empty string if there are no core files.
+func (srv *Server) describeCoreDumps() string {
+	paths, err := srv.CoreDumps()
+	if err != nil || len(paths) == 0 {
+		return ""
+	}
+	postgres, err := lookProgram("postgres")
+	if err != nil {
+		return "core dumps: " + strings.Join(paths, ", ")
+	}
+	sb := new(strings.Builder)
+	for _, path := range paths {
+		fmt.Fprintf(sb, "core dump: %s\n", path)
+		if bt := backtrace(postgres, path); bt != "" {
+			sb.WriteString(bt)
+			if !strings.HasSuffix(bt, "\n") {
+				sb.WriteString("\n")
+			}
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// backtrace returns the backtrace gdb prints for the given core file
+// of the given program, or the empty string if gdb is not available.
+func backtrace(program, corePath string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), backtraceTimeout)
+	defer cancel()
+	gdb, err := exec.LookPath("gdb")
+	if err != nil {
+		return ""
+	}
+	out, err := exec.CommandContext(ctx, gdb, "--batch", "--quiet", "-ex", "bt", program, corePath).Output()
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
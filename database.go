@@ -0,0 +1,249 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Database is a database created by Server.CreateDatabaseHandle.
+type Database struct {
+	srv  *Server
+	name string
+}
+
+// CreateDatabaseHandle creates a new database on the server
+// like CreateDatabase, but returns a handle to it
+// that can be used to connect to it and drop it.
+func (srv *Server) CreateDatabaseHandle(ctx context.Context, opts ...DatabaseOption) (*Database, error) {
+	dbName, err := srv.createDatabase(ctx, newDatabaseConfig(opts))
+	if err != nil {
+		return nil, fmt.Errorf("new database: %w", err)
+	}
+	return &Database{srv: srv, name: dbName}, nil
+}
+
+// Name returns the database's name.
+func (db *Database) Name() string {
+	return db.name
+}
+
+// DSN returns the database's data source name.
+func (db *Database) DSN() string {
+	return db.srv.DSN(db.name)
+}
+
+// Open opens a connection to the database and checks that it is reachable.
+// The returned *sql.DB is closed by Server.Cleanup if it is still open.
+func (db *Database) Open(ctx context.Context) (*sql.DB, error) {
+	pool, err := db.srv.openDB(db.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("open database %q: %w", db.name, err)
+	}
+	if err := pool.PingContext(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("open database %q: %w", db.name, err)
+	}
+	return pool, nil
+}
+
+// Drop drops the database, terminating any connections to it.
+func (db *Database) Drop(ctx context.Context) error {
+	return db.srv.DropDatabase(ctx, db.name)
+}
+
+// A DatabaseOption customizes a database created by
+// Server.CreateDatabase or Server.NewDatabase.
+type DatabaseOption func(*databaseConfig)
+
+type databaseConfig struct {
+	template       string
+	encoding       string
+	locale         string
+	localeProvider string
+	icuLocale      string
+	oid            uint32
+}
+
+func newDatabaseConfig(opts []DatabaseOption) *databaseConfig {
+	cfg := new(databaseConfig)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// createStatement returns the CREATE DATABASE statement
+// for a database with the given name.
+func (cfg *databaseConfig) createStatement(dbName string) string {
+	sb := new(strings.Builder)
+	sb.WriteString("CREATE DATABASE ")
+	sb.WriteString(quoteIdentifier(dbName))
+	template := cfg.template
+	if cfg.encoding != "" || cfg.locale != "" || cfg.localeProvider != "" || cfg.icuLocale != "" {
+		// template1 may contain data in its own encoding and locale,
+		// so PostgreSQL only allows changing them when copying template0.
+		template = "template0"
+	}
+	if template != "" {
+		sb.WriteString(" TEMPLATE ")
+		sb.WriteString(quoteIdentifier(template))
+	}
+	if cfg.encoding != "" {
+		sb.WriteString(" ENCODING ")
+		sb.WriteString(quoteLiteral(cfg.encoding))
+	}
+	if cfg.locale != "" {
+		sb.WriteString(" LC_COLLATE ")
+		sb.WriteString(quoteLiteral(cfg.locale))
+		sb.WriteString(" LC_CTYPE ")
+		sb.WriteString(quoteLiteral(cfg.locale))
+	}
+	if cfg.localeProvider != "" {
+		sb.WriteString(" LOCALE_PROVIDER ")
+		sb.WriteString(quoteLiteral(cfg.localeProvider))
+	}
+	if cfg.icuLocale != "" {
+		sb.WriteString(" ICU_LOCALE ")
+		sb.WriteString(quoteLiteral(cfg.icuLocale))
+	}
+	if cfg.oid != 0 {
+		sb.WriteString(" OID ")
+		sb.WriteString(strconv.FormatUint(uint64(cfg.oid), 10))
+	}
+	sb.WriteString(";")
+	return sb.String()
+}
+
+// explainCreateError adds advice to an error from the CREATE DATABASE
+// statement returned by createStatement.
+func (cfg *databaseConfig) explainCreateError(err error) error {
+	if cfg.encoding != "" && strings.Contains(err.Error(), "does not match locale") {
+		if cfg.locale == "" {
+			return fmt.Errorf("%w (the server's default locale does not support encoding %s; "+
+				"use the Locale option to choose a locale that does, like \"C\")", err, cfg.encoding)
+		}
+		return fmt.Errorf("%w (locale %q does not support encoding %s)", err, cfg.locale, cfg.encoding)
+	}
+	return err
+}
+
+// minVersion returns the earliest major version of PostgreSQL
+// that supports the options, or zero if all versions do.
+func (cfg *databaseConfig) minVersion() int {
+	if cfg.localeProvider != "" || cfg.icuLocale != "" || cfg.oid != 0 {
+		return 15
+	}
+	return 0
+}
+
+// checkDatabaseConfig returns an error if the server does not support
+// the database options.
+func (srv *Server) checkDatabaseConfig(ctx context.Context, cfg *databaseConfig) error {
+	min := cfg.minVersion()
+	if min == 0 {
+		return nil
+	}
+	version, err := srv.majorVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version < min {
+		return fmt.Errorf("database options require PostgreSQL %d or later (server is PostgreSQL %d)", min, version)
+	}
+	return nil
+}
+
+// majorVersion returns the major version of the running server,
+// like 16. For versions before 10, it returns the first component,
+// like 9.
+func (srv *Server) majorVersion(ctx context.Context) (int, error) {
+	var num int
+	if err := srv.conn.QueryRowContext(ctx, "SELECT current_setting('server_version_num')::integer;").Scan(&num); err != nil {
+		return 0, err
+	}
+	return num / 10000, nil
+}
+
+// FromTemplate0 creates the database as a copy of template0
+// instead of template1, the default. template0 contains only the
+// standard objects PostgreSQL ships with, so the database will not
+// contain any objects added to template1.
+func FromTemplate0() DatabaseOption {
+	return func(cfg *databaseConfig) {
+		cfg.template = "template0"
+	}
+}
+
+// Encoding sets the character set of the database, like "LATIN1" or "UTF8",
+// so that tests can exercise conversion between the database's encoding
+// and the client's. The database is created from template0,
+// since template1 may contain data that the encoding cannot represent.
+// The database's locale must support the encoding:
+// if the server's default locale does not, use Locale as well.
+// The "C" locale supports every encoding.
+func Encoding(encoding string) DatabaseOption {
+	return func(cfg *databaseConfig) {
+		cfg.encoding = encoding
+	}
+}
+
+// Locale sets the database's collation order and character classification
+// (LC_COLLATE and LC_CTYPE), like "C" or "en_US.UTF-8".
+// The locale must be installed on the server's machine.
+// The database is created from template0, since the indexes in template1
+// depend on its locale.
+func Locale(locale string) DatabaseOption {
+	return func(cfg *databaseConfig) {
+		cfg.locale = locale
+	}
+}
+
+// LocaleProvider sets the provider of the database's default collation:
+// "libc", "icu", or (in PostgreSQL 17 and later) "builtin".
+// The database is created from template0, since template1 uses
+// the provider chosen by initdb.
+// LocaleProvider requires PostgreSQL 15 or later.
+func LocaleProvider(provider string) DatabaseOption {
+	return func(cfg *databaseConfig) {
+		cfg.localeProvider = provider
+	}
+}
+
+// ICULocale sets the ICU locale of the database's default collation,
+// like "en-US" or "und-u-ks-level2". The locale provider must be "icu".
+// The database is created from template0, like with Locale.
+// ICULocale requires PostgreSQL 15 or later.
+func ICULocale(locale string) DatabaseOption {
+	return func(cfg *databaseConfig) {
+		cfg.icuLocale = locale
+	}
+}
+
+// DatabaseOID sets the object identifier of the database,
+// so that it matches a database from another cluster.
+// The identifier must not already be in use.
+// DatabaseOID requires PostgreSQL 15 or later.
+func DatabaseOID(oid uint32) DatabaseOption {
+	return func(cfg *databaseConfig) {
+		cfg.oid = oid
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestFromTemplate0(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	template1, err := sql.Open("postgres", srv.DSN("template1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = template1.ExecContext(ctx, `CREATE TABLE polluted (id INTEGER PRIMARY KEY);`)
+	template1.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts []DatabaseOption
+		want bool
+	}{
+		{name: "Default", want: true},
+		{name: "FromTemplate0", opts: []DatabaseOption{FromTemplate0()}, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, err := srv.NewDatabase(ctx, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			var got bool
+			err = db.QueryRowContext(ctx, `SELECT to_regclass('public.polluted') IS NOT NULL;`).Scan(&got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("table from template1 exists = %t; want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCreateStatement(t *testing.T) {
+	tests := []struct {
+		opts []DatabaseOption
+		want string
+	}{
+		{want: `CREATE DATABASE "foo";`},
+		{opts: []DatabaseOption{FromTemplate0()}, want: `CREATE DATABASE "foo" TEMPLATE "template0";`},
+		{
+			opts: []DatabaseOption{FromTemplate0(), LocaleProvider("icu"), ICULocale("en-US"), DatabaseOID(16500)},
+			want: `CREATE DATABASE "foo" TEMPLATE "template0" LOCALE_PROVIDER 'icu' ICU_LOCALE 'en-US' OID 16500;`,
+		},
+		{
+			opts: []DatabaseOption{LocaleProvider("icu"), ICULocale("en-US")},
+			want: `CREATE DATABASE "foo" TEMPLATE "template0" LOCALE_PROVIDER 'icu' ICU_LOCALE 'en-US';`,
+		},
+		{
+			opts: []DatabaseOption{Encoding("LATIN1"), Locale("C")},
+			want: `CREATE DATABASE "foo" TEMPLATE "template0" ENCODING 'LATIN1' LC_COLLATE 'C' LC_CTYPE 'C';`,
+		},
+	}
+	for _, test := range tests {
+		if got := newDatabaseConfig(test.opts).createStatement("foo"); got != test.want {
+			t.Errorf("createStatement(\"foo\") = %q; want %q", got, test.want)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx, Encoding("LATIN1"), Locale("C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbName := strings.TrimPrefix(mustParseURL(t, dsn).Path, "/")
+	var encoding string
+	err = srv.conn.QueryRowContext(ctx, `SELECT pg_encoding_to_char(encoding) FROM pg_database WHERE datname = $1;`, dbName).Scan(&encoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "LATIN1"; encoding != want {
+		t.Errorf("encoding = %q; want %q", encoding, want)
+	}
+
+	t.Run("IncompatibleLocale", func(t *testing.T) {
+		var ctype string
+		if err := srv.conn.QueryRowContext(ctx, `SELECT datctype FROM pg_database WHERE datname = 'template0';`).Scan(&ctype); err != nil {
+			t.Fatal(err)
+		}
+		if ctype == "C" || ctype == "POSIX" {
+			t.Skipf("server's default locale %q supports every encoding", ctype)
+		}
+		_, err := srv.CreateDatabase(ctx, Encoding("LATIN1"))
+		if err == nil {
+			t.Fatalf("CreateDatabase(ctx, Encoding(\"LATIN1\")) with locale %q did not return an error", ctype)
+		}
+		if want := "Locale option"; !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %v; want to mention %q", err, want)
+		}
+	})
+}
+
+func TestLocaleProvider(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	if v, err := srv.majorVersion(ctx); err != nil {
+		t.Fatal(err)
+	} else if v < 15 {
+		t.Skipf("PostgreSQL %d does not support locale providers", v)
+	}
+	var hasICU bool
+	if err := srv.conn.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM pg_collation WHERE collprovider = 'i');`).Scan(&hasICU); err != nil {
+		t.Fatal(err)
+	}
+	if !hasICU {
+		t.Skip("PostgreSQL built without ICU")
+	}
+
+	const oid = 54321
+	dsn, err := srv.CreateDatabase(ctx, LocaleProvider("icu"), ICULocale("en-US"), DatabaseOID(oid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbName := strings.TrimPrefix(mustParseURL(t, dsn).Path, "/")
+	var gotProvider string
+	var gotOID uint32
+	err = srv.conn.QueryRowContext(ctx, `SELECT datlocprovider, oid FROM pg_database WHERE datname = $1;`, dbName).Scan(&gotProvider, &gotOID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotProvider != "i" {
+		t.Errorf("datlocprovider = %q; want %q", gotProvider, "i")
+	}
+	if gotOID != oid {
+		t.Errorf("oid = %d; want %d", gotOID, oid)
+	}
+}
+
+func TestCreateDatabaseHandle(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	h, err := srv.CreateDatabaseHandle(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := h.DSN(), srv.DSN(h.Name()); got != want {
+		t.Errorf("h.DSN() = %q; want %q", got, want)
+	}
+	db, err := h.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if err := db.QueryRowContext(ctx, `SELECT current_database();`).Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != h.Name() {
+		t.Errorf("current_database() = %q; want %q", got, h.Name())
+	}
+	db.Close()
+
+	if err := h.Drop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var exists bool
+	if err := srv.conn.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = $1);`, h.Name()).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("Database exists after Drop")
+	}
+}
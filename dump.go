@@ -0,0 +1,97 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CreateDatabaseFromDump creates a new database on the server, restores the
+// given dump into it, and returns the database's data source name.
+// dumpPath may be a plain SQL script, which is run with psql,
+// or an archive produced by pg_dump in the custom, directory, or tar format,
+// which is restored with pg_restore. Ownership and privileges in archives
+// are not restored, since the roles they refer to usually don't exist.
+// If restoring fails, the new database is dropped.
+func (srv *Server) CreateDatabaseFromDump(ctx context.Context, dumpPath string) (_ string, err error) {
+	archive, err := isDumpArchive(dumpPath)
+	if err != nil {
+		return "", fmt.Errorf("create database from dump: %w", err)
+	}
+	dbName, err := srv.createDatabase(ctx, newDatabaseConfig(nil))
+	if err != nil {
+		return "", fmt.Errorf("create database from dump: %w", err)
+	}
+	dsn := srv.DSN(dbName)
+	if archive {
+		err = runCommandContext(ctx, "pg_restore",
+			"--no-owner",
+			"--no-acl",
+			"--exit-on-error",
+			"--dbname="+dsn,
+			dumpPath)
+	} else {
+		err = runCommandContext(ctx, "psql",
+			"--no-psqlrc",
+			"--quiet",
+			"--set=ON_ERROR_STOP=1",
+			"--file="+dumpPath,
+			"--dbname="+dsn)
+	}
+	if err != nil {
+		// Use a fresh context in case the original was canceled.
+		srv.dropDatabase(context.Background(), dbName)
+		return "", fmt.Errorf("create database from dump %s: %w", dumpPath, err)
+	}
+	return dsn, nil
+}
+
+// isDumpArchive reports whether the file at path is an archive
+// that must be restored with pg_restore.
+func isDumpArchive(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() {
+		// Directory format dumps have a table of contents file
+		// in the custom format.
+		path = filepath.Join(path, "toc.dat")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	header := make([]byte, 512)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	header = header[:n]
+	if bytes.HasPrefix(header, []byte("PGDMP")) {
+		return true, nil
+	}
+	// Tar format dumps are tar files whose first member is toc.dat.
+	isTar := len(header) == 512 && bytes.Equal(header[257:262], []byte("ustar"))
+	return isTar && bytes.HasPrefix(header, []byte("toc.dat\x00")), nil
+}
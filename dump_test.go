@@ -0,0 +1,124 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateDatabaseFromDump(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dir := makeTempDir(t)
+
+	t.Run("Plain", func(t *testing.T) {
+		dumpPath := filepath.Join(dir, "plain.sql")
+		const dump = "CREATE TABLE foo (id SERIAL PRIMARY KEY);\n" +
+			"INSERT INTO foo DEFAULT VALUES;\n"
+		if err := ioutil.WriteFile(dumpPath, []byte(dump), 0666); err != nil {
+			t.Fatal(err)
+		}
+		dsn, err := srv.CreateDatabaseFromDump(ctx, dumpPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		var n int
+		if err := db.QueryRowContext(ctx, `SELECT count(*) FROM foo;`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("foo has %d rows; want 1", n)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var countBefore int
+		err := srv.conn.QueryRowContext(ctx, `SELECT count(*) FROM pg_database;`).Scan(&countBefore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dumpPath := filepath.Join(dir, "bad.sql")
+		if err := ioutil.WriteFile(dumpPath, []byte("SELECT bork;\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.CreateDatabaseFromDump(ctx, dumpPath); err == nil {
+			t.Error("CreateDatabaseFromDump did not return an error")
+		}
+		var countAfter int
+		err = srv.conn.QueryRowContext(ctx, `SELECT count(*) FROM pg_database;`).Scan(&countAfter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if countAfter != countBefore {
+			t.Errorf("%d databases after failed restore; want %d", countAfter, countBefore)
+		}
+	})
+}
+
+func TestIsDumpArchive(t *testing.T) {
+	dir := makeTempDir(t)
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "plain.sql", content: "CREATE TABLE foo ();\n", want: false},
+		{name: "empty.sql", content: "", want: false},
+		{name: "custom.dump", content: "PGDMP\x01\x0e\x00", want: true},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		if err := ioutil.WriteFile(path, []byte(test.content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		got, err := isDumpArchive(path)
+		if got != test.want || err != nil {
+			t.Errorf("isDumpArchive(%q) = %t, %v; want %t, <nil>", test.name, got, err, test.want)
+		}
+	}
+}
+
+// makeTempDir creates a temporary directory
+// that is removed at the end of the test.
+func makeTempDir(tb testing.TB) string {
+	tb.Helper()
+	dir, err := ioutil.TempDir("", "postgrestest_test")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := os.RemoveAll(dir); err != nil {
+			tb.Error(err)
+		}
+	})
+	return dir
+}
@@ -0,0 +1,251 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Settings returns the server's current run-time parameters
+// as reported by the pg_settings view of the default database.
+// Values are in the parameter's base unit, as in pg_settings.setting.
+func (srv *Server) Settings(ctx context.Context) (map[string]string, error) {
+	settings, err := srv.queryMap(ctx, "SELECT name, setting FROM pg_settings;")
+	if err != nil {
+		return nil, fmt.Errorf("read settings: %w", err)
+	}
+	return settings, nil
+}
+
+// BuildInfo returns details of how the server was built,
+// so tests that depend on a compile-time feature can skip
+// when the feature is absent. The result combines the pg_config view,
+// whose keys are the upper-case names printed by the pg_config program
+// (like "VERSION" and "CONFIGURE", which lists options like --with-llvm),
+// with the read-only parameters fixed when the server was compiled
+// or initialized (like "block_size" and "data_checksums").
+func (srv *Server) BuildInfo(ctx context.Context) (map[string]string, error) {
+	info, err := srv.queryMap(ctx, "SELECT name, setting FROM pg_config "+
+		"UNION ALL SELECT name, setting FROM pg_settings WHERE context = 'internal';")
+	if err != nil {
+		return nil, fmt.Errorf("read build info: %w", err)
+	}
+	return info, nil
+}
+
+// AvailableExtensions returns the extensions that are installed
+// on the server's machine and can be created with CREATE EXTENSION,
+// mapped to their default versions, as reported by pg_available_extensions.
+// Tests can use it to skip when an extension they need is not installed.
+// The extensions include those in directories added with WithExtensionDir.
+func (srv *Server) AvailableExtensions(ctx context.Context) (map[string]string, error) {
+	exts, err := srv.queryMap(ctx, "SELECT name, coalesce(default_version, '') FROM pg_available_extensions;")
+	if err != nil {
+		return nil, fmt.Errorf("list available extensions: %w", err)
+	}
+	return exts, nil
+}
+
+// queryMap runs a query on the administrative connection
+// that returns name and value columns and collects the rows into a map.
+func (srv *Server) queryMap(ctx context.Context, query string) (map[string]string, error) {
+	rows, err := srv.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	m := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		m[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DatabaseSize returns the disk space in bytes used by the database
+// with the given name, as reported by pg_database_size.
+// Space freed by deleting rows is not returned to the operating system
+// until the table is vacuumed, so the size is useful for testing
+// that vacuuming or bulk deletes behave as expected.
+func (srv *Server) DatabaseSize(ctx context.Context, dbName string) (int64, error) {
+	var size int64
+	if err := srv.conn.QueryRowContext(ctx, "SELECT pg_database_size($1);", dbName).Scan(&size); err != nil {
+		return 0, fmt.Errorf("database size of %q: %w", dbName, err)
+	}
+	return size, nil
+}
+
+// ExplainJSON runs the query with EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON)
+// on the database with the given data source name and returns the plan.
+// Since ANALYZE executes the query, any changes the query makes are kept.
+func (srv *Server) ExplainJSON(ctx context.Context, dsn, query string, args ...interface{}) (json.RawMessage, error) {
+	db, err := sql.Open(srv.cfg.driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("explain: %w", err)
+	}
+	defer db.Close()
+	var plan []byte
+	err = db.QueryRowContext(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "+query, args...).Scan(&plan)
+	if err != nil {
+		return nil, fmt.Errorf("explain: %w", err)
+	}
+	return json.RawMessage(plan), nil
+}
+
+// ConnWithPID opens a single connection to the database with the given name
+// and returns it along with the process ID of its backend,
+// so that tests can target the backend with functions like
+// pg_terminate_backend or find its locks in pg_locks.
+// The caller must call the returned function to close the connection
+// once it is done with it.
+func (srv *Server) ConnWithPID(ctx context.Context, dbName string) (*sql.Conn, int, func(), error) {
+	db, err := sql.Open(srv.cfg.driverName, srv.DSN(dbName))
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("connect to %q: %w", dbName, err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		return nil, 0, nil, fmt.Errorf("connect to %q: %w", dbName, err)
+	}
+	closeFunc := func() {
+		conn.Close()
+		db.Close()
+	}
+	var pid int
+	if err := conn.QueryRowContext(ctx, "SELECT pg_backend_pid();").Scan(&pid); err != nil {
+		closeFunc()
+		return nil, 0, nil, fmt.Errorf("connect to %q: %w", dbName, err)
+	}
+	return conn, pid, closeFunc, nil
+}
+
+// AssertNoLeaks reports an error to tb for each session
+// still connected to the given database, other than the server's own
+// administrative connection. Sessions that close shortly after the call
+// are given a moment to disconnect before they are counted.
+// It is intended to be registered with tb.Cleanup after the code under test
+// has had the chance to close its connections:
+//
+//	t.Cleanup(func() { srv.AssertNoLeaks(t, dbName) })
+func (srv *Server) AssertNoLeaks(tb TB, dbName string) {
+	tb.Helper()
+	deadline := time.Now().Add(leakGracePeriod)
+	for {
+		sessions, err := srv.activity(context.Background(), dbName)
+		if err != nil {
+			tb.Errorf("check for leaked connections to %q: %v", dbName, err)
+			return
+		}
+		if len(sessions) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			for _, sess := range sessions {
+				tb.Errorf("leaked connection to %q: %v", dbName, sess)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// leakGracePeriod is how long AssertNoLeaks waits
+// for closed connections' backends to exit.
+const leakGracePeriod = 1 * time.Second
+
+// A Backend is a server process, as reported by the pg_stat_activity view.
+type Backend struct {
+	PID int
+	// Database is the name of the database the backend is connected to,
+	// or empty for background processes.
+	Database        string
+	User            string
+	ApplicationName string
+	// State is the backend's current state, like "active" or "idle",
+	// or empty for background processes.
+	State string
+	// Query is the text of the backend's most recent query.
+	Query         string
+	WaitEventType string
+	WaitEvent     string
+}
+
+// String returns a summary of the backend for error messages.
+func (b Backend) String() string {
+	return fmt.Sprintf("pid %d user=%q application_name=%q state=%q query=%q",
+		b.PID, b.User, b.ApplicationName, b.State, b.Query)
+}
+
+// Activity returns the server's processes, other than the backend
+// of the server's administrative connection, ordered by process ID.
+// This includes background processes like the autovacuum launcher.
+func (srv *Server) Activity(ctx context.Context) ([]Backend, error) {
+	backends, err := srv.activity(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("read activity: %w", err)
+	}
+	return backends, nil
+}
+
+// activity returns the rows of pg_stat_activity other than srv.conn's.
+// If dbName is not empty, only backends connected to it are returned.
+func (srv *Server) activity(ctx context.Context, dbName string) ([]Backend, error) {
+	q := "SELECT pid, datname, usename, application_name, state, query, wait_event_type, wait_event " +
+		"FROM pg_stat_activity WHERE pid <> pg_backend_pid()"
+	var args []interface{}
+	if dbName != "" {
+		q += " AND datname = $1"
+		args = append(args, dbName)
+	}
+	rows, err := srv.conn.QueryContext(ctx, q+" ORDER BY pid;", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var backends []Backend
+	for rows.Next() {
+		var b Backend
+		var datname, user, appName, state, query, waitEventType, waitEvent sql.NullString
+		if err := rows.Scan(&b.PID, &datname, &user, &appName, &state, &query, &waitEventType, &waitEvent); err != nil {
+			return nil, err
+		}
+		b.Database = datname.String
+		b.User = user.String
+		b.ApplicationName = appName.String
+		b.State = state.String
+		b.Query = strings.TrimSpace(query.String)
+		b.WaitEventType = waitEventType.String
+		b.WaitEvent = waitEvent.String
+		backends = append(backends, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return backends, nil
+}
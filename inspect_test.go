@@ -0,0 +1,290 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSettings(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithConfig("application_name", "xyzzy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	settings, err := srv.Settings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := settings["fsync"], "off"; got != want {
+		t.Errorf("settings[%q] = %q; want %q", "fsync", got, want)
+	}
+	if got, want := settings["application_name"], "xyzzy"; got != want {
+		t.Errorf("settings[%q] = %q; want %q", "application_name", got, want)
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	info, err := srv.BuildInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info["VERSION"]; !strings.HasPrefix(got, "PostgreSQL ") {
+		t.Errorf("info[%q] = %q; want to start with %q", "VERSION", got, "PostgreSQL ")
+	}
+	if _, ok := info["CONFIGURE"]; !ok {
+		t.Errorf("info[%q] missing", "CONFIGURE")
+	}
+	if got, want := info["block_size"], "8192"; got != want {
+		t.Errorf("info[%q] = %q; want %q", "block_size", got, want)
+	}
+}
+
+func TestAvailableExtensions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	exts, err := srv.AvailableExtensions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// PL/pgSQL is always installed.
+	if got := exts["plpgsql"]; got == "" {
+		t.Errorf("exts[%q] = %q; want a version", "plpgsql", got)
+	}
+	if _, ok := exts["postgrestest_nonexistent"]; ok {
+		t.Errorf("exts contains %q", "postgrestest_nonexistent")
+	}
+}
+
+func TestDatabaseSize(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbName := strings.TrimPrefix(mustParseURL(t, dsn).Path, "/")
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	empty, err := srv.DatabaseSize(ctx, dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty <= 0 {
+		t.Errorf("size of new database = %d; want > 0", empty)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE TABLE foo AS SELECT i, repeat('x', 100) AS s FROM generate_series(1, 100000) i;`); err != nil {
+		t.Fatal(err)
+	}
+	full, err := srv.DatabaseSize(ctx, dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full <= empty {
+		t.Errorf("size after insert = %d; want > %d", full, empty)
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM foo;`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `VACUUM FULL foo;`); err != nil {
+		t.Fatal(err)
+	}
+	vacuumed, err := srv.DatabaseSize(ctx, dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vacuumed >= full {
+		t.Errorf("size after delete and vacuum = %d; want < %d", vacuumed, full)
+	}
+}
+
+func TestExplainJSON(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	plan, err := srv.ExplainJSON(ctx, srv.DefaultDatabase(), "SELECT oid FROM pg_class WHERE oid = $1;", 1259)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed []struct {
+		Plan struct {
+			NodeType   string  `json:"Node Type"`
+			ActualRows float64 `json:"Actual Rows"`
+		}
+	}
+	if err := json.Unmarshal(plan, &parsed); err != nil {
+		t.Fatalf("parse plan %s: %v", plan, err)
+	}
+	if len(parsed) != 1 {
+		t.Fatalf("plan = %s; want one element", plan)
+	}
+	if parsed[0].Plan.NodeType == "" {
+		t.Errorf("plan = %s; missing node type", plan)
+	}
+	if parsed[0].Plan.ActualRows != 1 {
+		t.Errorf("actual rows = %v; want 1", parsed[0].Plan.ActualRows)
+	}
+}
+
+func TestActivity(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithDatabases("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := sql.Open("postgres", srv.DSN("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var pid int
+	if err := conn.QueryRowContext(ctx, `SELECT pg_backend_pid();`).Scan(&pid); err != nil {
+		t.Fatal(err)
+	}
+
+	backends, err := srv.Activity(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range backends {
+		if b.PID != pid {
+			continue
+		}
+		if b.Database != "app" || b.User != "postgres" || b.State != "idle" || b.Query != "SELECT pg_backend_pid();" {
+			t.Errorf("Backend for connection = %+v; want idle in database \"app\" after SELECT pg_backend_pid()", b)
+		}
+		return
+	}
+	t.Errorf("Activity() = %+v; does not include pid %d", backends, pid)
+}
+
+func TestConnWithPID(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithDatabases("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	conn, pid, closeConn, err := srv.ConnWithPID(ctx, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeConn()
+	var terminated bool
+	if err := srv.conn.QueryRowContext(ctx, `SELECT pg_terminate_backend($1);`, pid).Scan(&terminated); err != nil {
+		t.Fatal(err)
+	}
+	if !terminated {
+		t.Fatalf("pg_terminate_backend(%d) = false", pid)
+	}
+	if err := conn.PingContext(ctx); err == nil {
+		t.Error("Connection still usable after terminating its backend")
+	}
+}
+
+func TestAssertNoLeaks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := mustParseURL(t, dsn)
+	dbName := strings.TrimPrefix(u.Path, "/")
+	q := u.Query()
+	q.Set("application_name", "leaky")
+	u.RawQuery = q.Encode()
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := new(errorRecorder)
+	srv.AssertNoLeaks(rec, dbName)
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], `application_name="leaky"`) {
+		t.Errorf("AssertNoLeaks with open connection reported %q; want one error naming the leak", rec.errors)
+	}
+
+	conn.Close()
+	db.Close()
+	rec = new(errorRecorder)
+	srv.AssertNoLeaks(rec, dbName)
+	if len(rec.errors) > 0 {
+		t.Errorf("AssertNoLeaks after close reported %q", rec.errors)
+	}
+}
+
+// errorRecorder is a testing.TB that records calls to Errorf.
+type errorRecorder struct {
+	testing.TB
+	errors []string
+}
+
+func (rec *errorRecorder) Helper() {}
+
+func (rec *errorRecorder) Errorf(format string, args ...interface{}) {
+	rec.errors = append(rec.errors, fmt.Sprintf(format, args...))
+}
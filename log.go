@@ -0,0 +1,198 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// A tbWriter logs each line written to it to a TB.
+// Output written after the test finishes is discarded.
+type tbWriter struct {
+	tb     TB
+	prefix string
+
+	mu   sync.Mutex
+	buf  []byte
+	done bool
+}
+
+func newTBWriter(tb TB, prefix string) *tbWriter {
+	w := &tbWriter{tb: tb, prefix: prefix}
+	tb.Cleanup(w.close)
+	return w
+}
+
+func (w *tbWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return len(p), nil
+	}
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i == -1 {
+			break
+		}
+		w.tb.Log(w.prefix + string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// close logs any partial line and discards any further output.
+func (w *tbWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.tb.Log(w.prefix + string(w.buf))
+		w.buf = nil
+	}
+	w.done = true
+}
+
+// A transcript interleaves the output of several sources
+// into a single writer, prefixing each line with its source.
+type transcript struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// phase returns a writer that writes lines to the transcript
+// with the given prefix. Partial lines are buffered
+// until their newline is written.
+func (t *transcript) phase(prefix string) io.Writer {
+	return &transcriptWriter{t: t, prefix: prefix}
+}
+
+type transcriptWriter struct {
+	t      *transcript
+	prefix string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *transcriptWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i == -1 {
+			break
+		}
+		w.t.mu.Lock()
+		_, err := io.WriteString(w.t.w, w.prefix+string(w.buf[:i+1]))
+		w.t.mu.Unlock()
+		w.buf = w.buf[i+1:]
+		if err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes any buffered partial line to the transcript
+// as a complete line.
+func (w *transcriptWriter) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) == 0 {
+		return nil
+	}
+	w.t.mu.Lock()
+	_, err := io.WriteString(w.t.w, w.prefix+string(w.buf)+"\n")
+	w.t.mu.Unlock()
+	w.buf = nil
+	return err
+}
+
+// flushTranscript flushes w if it is a writer returned by transcript.phase.
+func flushTranscript(w io.Writer) {
+	if tw, ok := w.(*transcriptWriter); ok {
+		tw.flush()
+	}
+}
+
+// A logTailer copies lines appended to a file to a writer.
+type logTailer struct {
+	path   string
+	w      io.Writer
+	offset int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// tailLog starts copying content appended to the file at path to w,
+// ignoring any content the file already has.
+func tailLog(path string, w io.Writer) *logTailer {
+	t := &logTailer{
+		path: path,
+		w:    w,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if info, err := os.Stat(path); err == nil {
+		t.offset = info.Size()
+	}
+	go t.run()
+	return t
+}
+
+func (t *logTailer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.copy()
+		case <-t.stop:
+			t.copy()
+			return
+		}
+	}
+}
+
+// copy writes any content appended to the file since the last call.
+func (t *logTailer) copy() {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return
+	}
+	n, _ := io.Copy(t.w, f)
+	t.offset += n
+}
+
+// close copies any remaining content, including a final partial line,
+// and stops the tailer.
+func (t *logTailer) close() {
+	close(t.stop)
+	<-t.done
+	flushTranscript(t.w)
+}
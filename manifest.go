@@ -0,0 +1,201 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// manifestFileName is the name of the file in a data directory
+// that records how the data directory was initialized.
+const manifestFileName = "postgrestest.json"
+
+// A manifest records how a data directory was initialized,
+// so that a data directory is only reused with compatible options.
+type manifest struct {
+	// Version is the content of the data directory's PG_VERSION file.
+	Version string `json:"version"`
+	// ConfigHash is the result of ConfigHash for the options
+	// used to initialize the data directory.
+	ConfigHash string `json:"config_hash"`
+	// Collate and Ctype are the LC_COLLATE and LC_CTYPE locales
+	// that initdb inherited from the environment.
+	Collate string `json:"lc_collate"`
+	Ctype   string `json:"lc_ctype"`
+	// Encoding is the server encoding that initdb derived from Ctype,
+	// or empty if Ctype does not name a character set.
+	Encoding string `json:"encoding"`
+	// ExtensionDirs is the list of directories given to WithExtensionDir.
+	ExtensionDirs []string `json:"extension_dirs,omitempty"`
+}
+
+// newManifest returns the manifest for a data directory
+// initialized by the given PostgreSQL version with cfg.
+func newManifest(version string, cfg *startConfig) *manifest {
+	ctype := initdbLocale("LC_CTYPE")
+	return &manifest{
+		Version:       version,
+		ConfigHash:    cfg.hash(version),
+		Collate:       initdbLocale("LC_COLLATE"),
+		Ctype:         ctype,
+		Encoding:      localeEncoding(ctype),
+		ExtensionDirs: cfg.extensionDirs,
+	}
+}
+
+// initdbLocale returns the locale that initdb uses for the given category
+// when no locale arguments are passed to it. Like setlocale,
+// it prefers LC_ALL, then the category's own variable, then LANG.
+func initdbLocale(category string) string {
+	for _, name := range []string{"LC_ALL", category, "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return "C"
+}
+
+// localeEncoding returns the server encoding initdb chooses for a locale:
+// SQL_ASCII for the C and POSIX locales, otherwise the locale's
+// character set (as in "en_US.UTF-8") in upper case without dashes.
+// It returns the empty string if the locale does not name a character set.
+func localeEncoding(locale string) string {
+	if locale == "C" || locale == "POSIX" {
+		return "SQL_ASCII"
+	}
+	i := strings.IndexByte(locale, '.')
+	if i == -1 {
+		return ""
+	}
+	codeset := locale[i+1:]
+	if j := strings.IndexByte(codeset, '@'); j != -1 {
+		codeset = codeset[:j]
+	}
+	return strings.ToUpper(strings.Replace(codeset, "-", "", -1))
+}
+
+// writeManifest writes a manifest file into a freshly initialized data directory.
+func writeManifest(dataDir string, cfg *startConfig) error {
+	version, err := readDataDirVersion(dataDir)
+	if err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	data, err := json.Marshal(newManifest(version, cfg))
+	if err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataDir, manifestFileName), data, 0600); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+// checkManifest verifies that the data directory was initialized
+// by a PostgreSQL version and options compatible with cfg.
+func checkManifest(dataDir string, cfg *startConfig) error {
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, manifestFileName))
+	if err != nil {
+		return fmt.Errorf("check manifest: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("check manifest: %s: %w", dataDir, err)
+	}
+	version, err := readDataDirVersion(dataDir)
+	if err != nil {
+		return fmt.Errorf("check manifest: %w", err)
+	}
+	if m.Version != version {
+		return fmt.Errorf("check manifest: %s was initialized for PostgreSQL %s, but contains PostgreSQL %s data", dataDir, m.Version, version)
+	}
+	want := newManifest(version, cfg)
+	if m.Collate != want.Collate || m.Ctype != want.Ctype {
+		return fmt.Errorf("check manifest: %s was initialized with LC_COLLATE=%s and LC_CTYPE=%s, but the environment has LC_COLLATE=%s and LC_CTYPE=%s",
+			dataDir, m.Collate, m.Ctype, want.Collate, want.Ctype)
+	}
+	if m.Encoding != want.Encoding {
+		return fmt.Errorf("check manifest: %s was initialized with encoding %q, but the environment implies %q", dataDir, m.Encoding, want.Encoding)
+	}
+	if !equalStrings(m.ExtensionDirs, want.ExtensionDirs) {
+		return fmt.Errorf("check manifest: %s was initialized with extension directories %q, but options give %q", dataDir, m.ExtensionDirs, want.ExtensionDirs)
+	}
+	if m.ConfigHash != want.ConfigHash {
+		return fmt.Errorf("check manifest: %s was initialized with different options", dataDir)
+	}
+	return nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkInstalledVersion verifies that the pg_ctl found by lookProgram
+// is from the major version of PostgreSQL recorded in the data directory's
+// manifest, so that the server that Resume starts can read the data.
+func checkInstalledVersion(dataDir string) error {
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, manifestFileName))
+	if err != nil {
+		return fmt.Errorf("check version: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("check version: %s: %w", dataDir, err)
+	}
+	installed, err := programMajorVersion("pg_ctl")
+	if err != nil {
+		return fmt.Errorf("check version: %w", err)
+	}
+	if installed != m.Version {
+		return fmt.Errorf("check version: %s was initialized by PostgreSQL %s, but pg_ctl is from PostgreSQL %s", dataDir, m.Version, installed)
+	}
+	return nil
+}
+
+// readDataDirVersion returns the major version of PostgreSQL
+// that initialized the given data directory.
+func readDataDirVersion(dataDir string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, "PG_VERSION"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// majorVersionNumber returns the leading number of a major version
+// like "16" or "9.6", or zero if the version is malformed.
+func majorVersionNumber(version string) int {
+	end := 0
+	for end < len(version) && '0' <= version[end] && version[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(version[:end])
+	return n
+}
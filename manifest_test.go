@@ -0,0 +1,96 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dataDir := makeTempDir(t)
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := newStartConfig(nil)
+	if err := writeManifest(dataDir, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkManifest(dataDir, cfg); err != nil {
+		t.Error("Same options:", err)
+	}
+	if err := checkManifest(dataDir, newStartConfig([]StartOption{WithFastStartup()})); err != nil {
+		t.Error("Options that don't affect initdb:", err)
+	}
+	if err := checkManifest(dataDir, newStartConfig([]StartOption{WithDirPerm(0750)})); err == nil {
+		t.Error("Different initdb options did not return an error")
+	}
+
+	if err := checkManifest(dataDir, newStartConfig([]StartOption{WithExtensionDir("/opt/ext")})); err == nil {
+		t.Error("Different extension directories did not return an error")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("17\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkManifest(dataDir, cfg); err == nil {
+		t.Error("Different version did not return an error")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, "LC_ALL", "xx_XX.ISO-8859-1")
+	if err := checkManifest(dataDir, cfg); err == nil {
+		t.Error("Different locale environment did not return an error")
+	}
+}
+
+func TestLocaleEncoding(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"C", "SQL_ASCII"},
+		{"POSIX", "SQL_ASCII"},
+		{"en_US.UTF-8", "UTF8"},
+		{"en_US.utf8", "UTF8"},
+		{"de_DE.ISO-8859-1@euro", "ISO88591"},
+		{"en_US", ""},
+	}
+	for _, test := range tests {
+		if got := localeEncoding(test.locale); got != test.want {
+			t.Errorf("localeEncoding(%q) = %q; want %q", test.locale, got, test.want)
+		}
+	}
+}
+
+// setenv sets an environment variable for the duration of the test.
+func setenv(tb testing.TB, name, value string) {
+	tb.Helper()
+	old, hadOld := os.LookupEnv(name)
+	if err := os.Setenv(name, value); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if hadOld {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MeasureStartup starts n servers one after another with the given options
+// and returns the average time Start took to return.
+// Each server is cleaned up before the next one starts,
+// and cleanup time is not included in the average.
+// MeasureStartup is intended for comparing the effect of options
+// like WithFastStartup in a particular environment.
+func MeasureStartup(ctx context.Context, n int, opts ...StartOption) (time.Duration, error) {
+	if n < 1 {
+		return 0, errors.New("measure startup: need at least one server")
+	}
+	var total time.Duration
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		srv, err := Start(ctx, opts...)
+		if err != nil {
+			return 0, fmt.Errorf("measure startup: %w", err)
+		}
+		total += time.Since(start)
+		srv.Cleanup()
+	}
+	return total / time.Duration(n), nil
+}
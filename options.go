@@ -0,0 +1,931 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A StartOption customizes the server created by Start.
+type StartOption func(*startConfig)
+
+type startConfig struct {
+	// settings maps postgresql.conf parameter names to their unquoted values.
+	settings map[string]string
+	// configFuncs are applied in order to settings
+	// before writing postgresql.conf.
+	configFuncs []func(map[string]string) map[string]string
+
+	jit           bool
+	jitSet        bool // whether WithJIT was used
+	minVersion    int
+	configFile    string
+	tempDirParent string
+	tempDirName   func() string
+	dirPerm       os.FileMode
+	walSegSize    int // in megabytes, or 0 for initdb's default
+	extensionDirs []string
+
+	sslMode    string
+	dsnParams  map[string]string
+	driverName string
+	adminConn  func(dsn string) (AdminConn, error)
+
+	readinessInitial time.Duration
+	readinessMax     time.Duration
+
+	databases         []string
+	warmup            []string // nil if warmup is disabled
+	initSQL           []func() (string, error)
+	preserveOnFailure TB
+	processLog        io.Writer
+	transcript        *transcript
+	warnOutput        io.Writer // for warnings without a transcript; nil for os.Stderr
+
+	replication    bool
+	coreDumps      bool
+	channelBinding bool
+
+	// err is the first error reported by an option.
+	// Start returns it before doing any work.
+	err error
+}
+
+// defaultSettings are the postgresql.conf parameters the package sets
+// unless an option sets them or WithConfigFile is used.
+var defaultSettings = map[string]string{
+	// We don't care about durability for tests.
+	"fsync":              "off",
+	"synchronous_commit": "off",
+	"full_page_writes":   "off",
+	// Attribute log lines to the process and database that wrote them.
+	"log_line_prefix": defaultLogLinePrefix,
+}
+
+func newStartConfig(opts []StartOption) *startConfig {
+	cfg := &startConfig{
+		settings:         make(map[string]string),
+		readinessInitial: 1 * time.Millisecond,
+		readinessMax:     25 * time.Millisecond,
+		driverName:       "postgres",
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// setErr records err if no option has reported an error yet.
+func (cfg *startConfig) setErr(err error) {
+	if cfg.err == nil {
+		cfg.err = err
+	}
+}
+
+// serverSettings returns the postgresql.conf parameters for a server
+// of the given major version, before applying configFuncs.
+func (cfg *startConfig) serverSettings(majorVersion int) (map[string]string, error) {
+	if majorVersion < cfg.minVersion {
+		return nil, fmt.Errorf("needs PostgreSQL %d or later (found PostgreSQL %d)", cfg.minVersion, majorVersion)
+	}
+	settings := make(map[string]string, len(cfg.settings)+len(defaultSettings)+3)
+	for k, v := range cfg.settings {
+		settings[k] = v
+	}
+	// A config file replaces the package's defaults, including jit.
+	if cfg.configFile == "" {
+		for k, v := range defaultSettings {
+			if _, set := settings[k]; !set {
+				settings[k] = v
+			}
+		}
+	}
+	if _, set := settings["jit"]; !set && majorVersion >= 11 && (cfg.configFile == "" || cfg.jitSet) {
+		settings["jit"] = boolSetting(cfg.jit)
+	}
+	if len(cfg.extensionDirs) > 0 {
+		if majorVersion < 18 {
+			return nil, fmt.Errorf("extension directories require PostgreSQL 18 or later (found PostgreSQL %d)", majorVersion)
+		}
+		settings["extension_control_path"] = strings.Join(append([]string{"$system"}, cfg.extensionDirs...), string(filepath.ListSeparator))
+		settings["dynamic_library_path"] = strings.Join(append([]string{"$libdir"}, cfg.extensionDirs...), string(filepath.ListSeparator))
+	}
+	return settings, nil
+}
+
+// boolSetting returns the postgresql.conf value for b.
+func boolSetting(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// tempDirRoot returns the directory where newServerDir
+// creates temporary directories.
+func (cfg *startConfig) tempDirRoot() string {
+	if cfg.tempDirParent == "" {
+		return os.TempDir()
+	}
+	return cfg.tempDirParent
+}
+
+// newServerDir creates a new temporary directory for a server.
+// The server's data directory, Unix socket, and log file are placed inside it.
+func (cfg *startConfig) newServerDir() (string, error) {
+	var dir string
+	if cfg.tempDirName != nil {
+		dir = filepath.Join(cfg.tempDirRoot(), cfg.tempDirName())
+		if err := os.Mkdir(dir, 0700); err != nil {
+			return "", err
+		}
+	} else {
+		var err error
+		dir, err = ioutil.TempDir(cfg.tempDirParent, "postgrestest")
+		if err != nil {
+			return "", err
+		}
+	}
+	if cfg.dirPerm != 0 {
+		if err := os.Chmod(dir, cfg.dirPerm); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// initdbArgs returns the arguments to pass to initdb
+// in addition to the data directory and superuser name.
+func (cfg *startConfig) initdbArgs() []string {
+	var args []string
+	if cfg.dirPerm&0070 != 0 {
+		args = append(args, "--allow-group-access")
+	}
+	if cfg.walSegSize != 0 {
+		args = append(args, "--wal-segsize="+strconv.Itoa(cfg.walSegSize))
+	}
+	return args
+}
+
+// ConfigHash returns a stable hash of the options that affect how a data
+// directory is initialized. Data directories initialized with options that
+// have the same hash are interchangeable, so the hash can be used as a key
+// to cache initialized data directories. The hash covers initdb's arguments,
+// the locale that initdb inherits from the LC_ALL, LC_COLLATE, LC_CTYPE,
+// and LANG environment variables, and the directories given to
+// WithExtensionDir, since extensions created in the data directory depend
+// on them, as well as the major version of the initdb program that Start
+// would run. If initdb cannot be found, the hash uses an empty version.
+// Other options that only affect the server after initialization,
+// such as postgresql.conf settings, do not change the hash.
+func ConfigHash(opts ...StartOption) string {
+	version, _ := programMajorVersion("initdb")
+	return newStartConfig(opts).hash(version)
+}
+
+// hash returns the ConfigHash of cfg for the given
+// major version of PostgreSQL.
+func (cfg *startConfig) hash(version string) string {
+	args := cfg.initdbArgs()
+	sort.Strings(args)
+	h := sha256.New()
+	h.Write([]byte("postgrestest initdb\x00"))
+	h.Write([]byte("version=" + version))
+	h.Write([]byte{0})
+	for _, arg := range args {
+		h.Write([]byte(arg))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte("\x00locale\x00"))
+	for _, category := range []string{"LC_COLLATE", "LC_CTYPE"} {
+		h.Write([]byte(category + "=" + initdbLocale(category)))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte("\x00extensions\x00"))
+	for _, dir := range cfg.extensionDirs {
+		h.Write([]byte(dir))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// openAdminConn opens the connection a Server uses
+// for administrative statements.
+func (cfg *startConfig) openAdminConn(dsn string) (AdminConn, error) {
+	if cfg.adminConn != nil {
+		return cfg.adminConn(dsn)
+	}
+	db, err := sql.Open(cfg.driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// WithTempDir sets where the server's temporary directory is created,
+// so that it can be found by external cleanup tools.
+// The directory is created inside parent, or inside os.TempDir()
+// if parent is empty. If name is not nil, it is called to choose
+// the directory's name, and Start fails if the directory already exists.
+// Otherwise, the name is "postgrestest" followed by a random string.
+// The Unix socket is placed inside the directory,
+// and Unix socket paths are limited to around 100 bytes,
+// so the directory's path should be short.
+func WithTempDir(parent string, name func() string) StartOption {
+	return func(cfg *startConfig) {
+		cfg.tempDirParent = parent
+		cfg.tempDirName = name
+	}
+}
+
+// WithDirPerm sets the permissions of the temporary directory that holds
+// the server's Unix socket, log file, and data directory.
+// The default is 0700. If perm grants any access to the group,
+// the data directory is initialized with group read access
+// (initdb --allow-group-access) so that processes running as
+// a different user in the same group can read the log and data files.
+// PostgreSQL never permits other users to access the data directory.
+func WithDirPerm(perm os.FileMode) StartOption {
+	return func(cfg *startConfig) {
+		cfg.dirPerm = perm.Perm()
+	}
+}
+
+// WithWALSegSize sets the size of the server's write-ahead log segments
+// in megabytes (initdb --wal-segsize). mb must be a power of two
+// between 1 and 1024. The size is fixed when the data directory
+// is initialized, so it is included in ConfigHash.
+func WithWALSegSize(mb int) StartOption {
+	return func(cfg *startConfig) {
+		if mb < 1 || mb > 1024 || mb&(mb-1) != 0 {
+			cfg.setErr(fmt.Errorf("WAL segment size %d MB is not a power of two between 1 and 1024", mb))
+			return
+		}
+		cfg.walSegSize = mb
+	}
+}
+
+// WithSSLMode sets the sslmode parameter of the data source names
+// the server returns: "disable", "allow", "prefer", "require",
+// "verify-ca", or "verify-full". PostgreSQL never uses TLS on its Unix socket,
+// so for the modes that require TLS ("require", "verify-ca", and
+// "verify-full"), the server also listens on a TCP port on the loopback
+// interface using a self-signed certificate, and the data source names
+// connect to that port. For "verify-ca" and "verify-full", the data source
+// names set sslrootcert to the certificate. The default is "disable",
+// or "require" if WithChannelBinding is used.
+// The server's administrative connection always uses the Unix socket.
+func WithSSLMode(mode string) StartOption {
+	return func(cfg *startConfig) {
+		switch mode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+			cfg.sslMode = mode
+		default:
+			cfg.setErr(fmt.Errorf("unknown sslmode %q", mode))
+		}
+	}
+}
+
+// dsnSSLMode returns the sslmode of the data source names the server returns.
+func (cfg *startConfig) dsnSSLMode() string {
+	switch {
+	case cfg.sslMode != "":
+		return cfg.sslMode
+	case cfg.channelBinding:
+		return "require"
+	default:
+		return "disable"
+	}
+}
+
+// dsnUsesTLS reports whether the data source names the server returns
+// connect over TCP with TLS instead of over the Unix socket.
+func (cfg *startConfig) dsnUsesTLS() bool {
+	switch cfg.dsnSSLMode() {
+	case "require", "verify-ca", "verify-full":
+		return true
+	default:
+		return false
+	}
+}
+
+// listenTLS reports whether the server listens for TLS connections over TCP.
+func (cfg *startConfig) listenTLS() bool {
+	return cfg.channelBinding || cfg.dsnUsesTLS()
+}
+
+// WithDSNParams adds the given parameters to the query string
+// of the data source names the server returns,
+// like "application_name" or "connect_timeout".
+// Parameters override any the package would otherwise set,
+// but they are not used for the server's administrative connection.
+func WithDSNParams(params map[string]string) StartOption {
+	return func(cfg *startConfig) {
+		if cfg.dsnParams == nil {
+			cfg.dsnParams = make(map[string]string)
+		}
+		for k, v := range params {
+			cfg.dsnParams[k] = v
+		}
+	}
+}
+
+// WithDriverName sets the database/sql driver name
+// that the server uses to open connections.
+// The default is "postgres", which is provided by github.com/lib/pq.
+// The driver must accept URL-style data source names.
+func WithDriverName(name string) StartOption {
+	return func(cfg *startConfig) {
+		cfg.driverName = name
+	}
+}
+
+// WithAdminConn sets the function the server uses to open its administrative
+// connection to the default database. The function is called with a
+// data source name for the default database once the server process has
+// started.
+// If WithAdminConn is not given, the server opens a *sql.DB
+// with the driver from WithDriverName.
+func WithAdminConn(open func(dsn string) (AdminConn, error)) StartOption {
+	return func(cfg *startConfig) {
+		cfg.adminConn = open
+	}
+}
+
+// WithConfig sets a parameter in the server's postgresql.conf file.
+// value is quoted as needed.
+func WithConfig(name, value string) StartOption {
+	return func(cfg *startConfig) {
+		cfg.settings[name] = value
+	}
+}
+
+// WithConfigFile uses the postgresql.conf file at the given path
+// as the base of the server's configuration, so tests run with
+// the same settings as production. The file is included as is,
+// so any files it includes by relative path are found relative to it.
+// The package's defaults, like turning off fsync and JIT compilation,
+// are not applied, but settings from other options, before or after
+// WithConfigFile, are kept and override the file's settings.
+//
+// The parameters that control where the server listens for connections
+// (listen_addresses, unix_socket_directories, and, for TCP connections,
+// port and the ssl parameters) are always set by the package.
+// If the file sets any of them, a warning is written to the transcript
+// set by WithTranscript, or to standard error if there is no transcript.
+func WithConfigFile(path string) StartOption {
+	return func(cfg *startConfig) {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			cfg.setErr(fmt.Errorf("config file: %w", err))
+			return
+		}
+		cfg.configFile = path
+	}
+}
+
+// WithConfigFunc registers a function that customizes the server's
+// postgresql.conf file. The function is called with the parameters the
+// package would write (the package's defaults plus any settings from other
+// options) and returns the parameters to write. Values are unquoted.
+// The function may modify and return the map it is given.
+// The parameters that control where the server listens for connections
+// are always set by the package and are not passed to the function.
+func WithConfigFunc(f func(settings map[string]string) map[string]string) StartOption {
+	return func(cfg *startConfig) {
+		cfg.configFuncs = append(cfg.configFuncs, f)
+	}
+}
+
+// WithReadinessBackoff sets how often Start checks whether the server is
+// accepting connections. Start waits initial after the first failed attempt
+// and doubles the wait after each subsequent attempt, up to max.
+// The default is 1ms initially, up to 25ms.
+// Start gives up when its Context is done.
+// Start returns an error if initial is not positive or max is less than initial.
+func WithReadinessBackoff(initial, max time.Duration) StartOption {
+	return func(cfg *startConfig) {
+		if initial <= 0 {
+			cfg.setErr(fmt.Errorf("readiness backoff %v is not positive", initial))
+			return
+		}
+		if max < initial {
+			cfg.setErr(fmt.Errorf("readiness backoff maximum %v is less than initial %v", max, initial))
+			return
+		}
+		cfg.readinessInitial = initial
+		cfg.readinessMax = max
+	}
+}
+
+// WithDatabases creates databases with the given names when the server starts.
+// Use Server.DSN to connect to them, or pass Server.EnvVars to programs
+// that expect a fixed database name.
+func WithDatabases(names ...string) StartOption {
+	return func(cfg *startConfig) {
+		cfg.databases = append(cfg.databases, names...)
+	}
+}
+
+// WithMessagesLocale sets the locale the server uses for error messages
+// (the lc_messages parameter), so that tests that inspect error text get the
+// same messages on every machine. "C" produces untranslated English messages.
+// By default, the server uses the locale of its environment.
+func WithMessagesLocale(locale string) StartOption {
+	return WithConfig("lc_messages", locale)
+}
+
+// WithInitSQLReader runs the SQL script read from r
+// against the default database once the server has started,
+// such as a schema embedded in the test binary with go:embed.
+// The script may contain multiple statements. r is read in full
+// the first time a server is started with the option,
+// so the option may be reused, as with StartCluster.
+// Scripts are run in the order their options are given,
+// before any statements given to WithWarmup.
+// Resume does not run the scripts, since the data directory
+// already contains their effects.
+func WithInitSQLReader(r io.Reader) StartOption {
+	var once sync.Once
+	var script string
+	var err error
+	read := func() (string, error) {
+		once.Do(func() {
+			var data []byte
+			data, err = ioutil.ReadAll(r)
+			script = string(data)
+		})
+		return script, err
+	}
+	return func(cfg *startConfig) {
+		cfg.initSQL = append(cfg.initSQL, read)
+	}
+}
+
+// WithPreserveOnFailure changes Server.Cleanup to leave the server running
+// if tb has failed, so that its databases can be inspected after the test.
+// Cleanup logs the data source names of the server's databases to tb
+// along with instructions for stopping the server.
+// Cleanup must be called after the test has finished,
+// such as by passing it to tb.Cleanup.
+func WithPreserveOnFailure(tb TB) StartOption {
+	return func(cfg *startConfig) {
+		cfg.preserveOnFailure = tb
+	}
+}
+
+// WithWarmup makes Start run a few queries against the system catalogs
+// after the server accepts connections, followed by the given statements,
+// so that timing-sensitive tests and benchmarks don't pay the cost of
+// loading the catalogs from disk on their first query.
+// The statements are run against the default database.
+func WithWarmup(stmts ...string) StartOption {
+	return func(cfg *startConfig) {
+		cfg.warmup = append(cfg.warmup, stmts...)
+		if cfg.warmup == nil {
+			cfg.warmup = []string{}
+		}
+	}
+}
+
+// WithProcessLog logs the output of the pg_ctl process that starts the server
+// to tb. This includes errors that occur before the server begins writing
+// to its log file, like a data directory with the wrong permissions.
+// Output after the test finishes is discarded.
+func WithProcessLog(tb TB) StartOption {
+	return func(cfg *startConfig) {
+		cfg.processLog = newTBWriter(tb, "pg_ctl: ")
+	}
+}
+
+// WithTranscript writes everything that happens while the server runs
+// to w: the output of initdb, the output of pg_ctl, and the server's log,
+// with each line prefixed by its source, like "initdb: " or "postgres: ".
+// This is useful for diagnosing startup failures.
+// Writes to w are serialized, but may continue until Cleanup returns.
+func WithTranscript(w io.Writer) StartOption {
+	return func(cfg *startConfig) {
+		cfg.transcript = &transcript{w: w}
+	}
+}
+
+// transcriptPhase returns a writer for the transcript lines
+// with the given prefix, or nil if WithTranscript was not given.
+func (cfg *startConfig) transcriptPhase(prefix string) io.Writer {
+	if cfg.transcript == nil {
+		return nil
+	}
+	return cfg.transcript.phase(prefix)
+}
+
+// warnings returns the writer for warnings about the server's setup:
+// the transcript if WithTranscript was used, or else standard error,
+// so that warnings are never dropped.
+func (cfg *startConfig) warnings() io.Writer {
+	if w := cfg.transcriptPhase("postgrestest: "); w != nil {
+		return w
+	}
+	out := cfg.warnOutput
+	if out == nil {
+		out = os.Stderr
+	}
+	return (&transcript{w: out}).phase("postgrestest: ")
+}
+
+// WithExtensionDir adds a directory to search for extensions
+// that are not installed in PostgreSQL's own directories,
+// like an extension under development.
+// The directory's "extension" subdirectory must contain
+// the extension's control and SQL script files,
+// and its shared libraries must be directly inside the directory.
+// This corresponds to installing the extension with
+// "make install datadir=DIR pkglibdir=DIR".
+// WithExtensionDir requires PostgreSQL 18 or later:
+// Start returns an error on earlier versions.
+func WithExtensionDir(dir string) StartOption {
+	return func(cfg *startConfig) {
+		cfg.extensionDirs = append(cfg.extensionDirs, filepath.ToSlash(dir))
+	}
+}
+
+// WithReplication configures the server to permit streaming replication.
+// Servers must be started with this option to use NewReplica.
+func WithReplication() StartOption {
+	return func(cfg *startConfig) {
+		cfg.replication = true
+		cfg.settings["wal_level"] = "replica"
+		cfg.settings["max_wal_senders"] = "10"
+		cfg.settings["hot_standby"] = "on"
+	}
+}
+
+// WithMinVersion makes Start and Resume return an error
+// if the server's major version is older than major,
+// so test suites that depend on newer features fail with a clear message
+// instead of a confusing error from a later statement.
+// The version is checked before the server is started.
+func WithMinVersion(major int) StartOption {
+	return func(cfg *startConfig) {
+		cfg.minVersion = major
+	}
+}
+
+// WithJIT sets whether the server uses just-in-time compilation
+// of queries (the jit parameter). The default is off,
+// since JIT compilation rarely speeds up the small queries in tests
+// and makes query plans and timings vary between machines.
+// WithJIT has no effect on versions of PostgreSQL before 11,
+// which do not support JIT compilation.
+func WithJIT(enabled bool) StartOption {
+	return func(cfg *startConfig) {
+		cfg.jit = enabled
+		cfg.jitSet = true
+	}
+}
+
+// WithClusterName sets the name that identifies the server
+// in the titles of its processes, as shown by ps
+// (the cluster_name parameter). The default is the base name
+// of the server's temporary directory, like "postgrestest123456".
+// The name may only contain printable ASCII characters.
+func WithClusterName(name string) StartOption {
+	return WithConfig("cluster_name", name)
+}
+
+// WithLogConnections makes the server log each connection and disconnection
+// (the log_connections and log_disconnections parameters)
+// to the file returned by Server.LogFile,
+// which helps track down leaked connections and pool churn.
+func WithLogConnections() StartOption {
+	return func(cfg *startConfig) {
+		cfg.settings["log_connections"] = "on"
+		cfg.settings["log_disconnections"] = "on"
+	}
+}
+
+// WithGEQO sets whether the planner uses the genetic query optimizer
+// for queries that join many tables (the geqo parameter).
+// GEQO searches join orders at random, so turning it off makes the plans
+// of such queries depend only on the query and table statistics,
+// at the cost of longer planning. If enabled, the random seed
+// (the geqo_seed parameter) is fixed at 0, so that repeated runs
+// still choose the same plans.
+func WithGEQO(enabled bool) StartOption {
+	return func(cfg *startConfig) {
+		cfg.settings["geqo"] = boolSetting(enabled)
+		cfg.settings["geqo_seed"] = "0"
+	}
+}
+
+// WithTrackIOTiming makes the server time its block reads and writes
+// (the track_io_timing parameter), so that EXPLAIN (ANALYZE, BUFFERS),
+// pg_stat_statements, and the blk_read_time and blk_write_time columns
+// of pg_stat_database report I/O timings. Timing adds overhead
+// on some platforms, so it is off by default.
+func WithTrackIOTiming() StartOption {
+	return WithConfig("track_io_timing", "on")
+}
+
+// defaultLogLinePrefix is the log_line_prefix used unless
+// WithLogLinePrefix is given: the time, process ID, and database name.
+const defaultLogLinePrefix = "%m [%p] %d "
+
+// WithLogLinePrefix sets the text at the start of each line
+// in the server's log file (the log_line_prefix parameter),
+// using the escapes described in the PostgreSQL documentation,
+// like %m for the time and %p for the process ID.
+// The default is "%m [%p] %d ", which adds the database name
+// to PostgreSQL's default so that log lines can be attributed to tests.
+func WithLogLinePrefix(prefix string) StartOption {
+	return WithConfig("log_line_prefix", prefix)
+}
+
+// WithAutovacuum sets whether the server runs the autovacuum daemon
+// (the autovacuum parameter). The default is on, as in production.
+// Turning it off keeps background vacuums and analyzes from changing
+// dead tuple counts and planner statistics while a test inspects them.
+func WithAutovacuum(enabled bool) StartOption {
+	return WithConfig("autovacuum", boolSetting(enabled))
+}
+
+// WithAutovacuumNaptime sets how often the autovacuum daemon checks
+// each database for tables that need vacuuming or analyzing
+// (the autovacuum_naptime parameter). The default is one minute,
+// longer than most tests run; a naptime of a second makes tests
+// that wait for autovacuum with Server.WaitForAutovacuum fast.
+// d is rounded down to a whole number of seconds
+// and must be at least 1 second.
+func WithAutovacuumNaptime(d time.Duration) StartOption {
+	return func(cfg *startConfig) {
+		sec := d / time.Second
+		if sec < 1 {
+			cfg.setErr(fmt.Errorf("autovacuum naptime %v is less than 1s", d))
+			return
+		}
+		cfg.settings["autovacuum_naptime"] = strconv.FormatInt(int64(sec), 10) + "s"
+	}
+}
+
+// WithMaxPreparedTransactions sets the number of transactions
+// that can be prepared for two-phase commit at once
+// (the max_prepared_transactions parameter).
+// The default is 0, which disables PREPARE TRANSACTION.
+func WithMaxPreparedTransactions(n int) StartOption {
+	return WithConfig("max_prepared_transactions", strconv.Itoa(n))
+}
+
+// WithCoreDumps makes the server's processes write core files
+// when they crash (pg_ctl --core-files), which is useful for debugging
+// native extensions. If the server exits unexpectedly, Server.Err
+// includes the paths of any core files and, if gdb is installed,
+// their backtraces. Server.CoreDumps lists the core files
+// left by crashed backends while the server keeps running.
+// Whether core files are written to the data directory
+// depends on the operating system's configuration.
+func WithCoreDumps() StartOption {
+	return func(cfg *startConfig) {
+		cfg.coreDumps = true
+	}
+}
+
+// WithMaxLocksPerTransaction sets the average number of objects
+// each transaction can lock (the max_locks_per_transaction parameter).
+// The default is 64, which transactions that touch many partitions
+// or tables can exceed, failing with "out of shared memory".
+func WithMaxLocksPerTransaction(n int) StartOption {
+	return WithConfig("max_locks_per_transaction", strconv.Itoa(n))
+}
+
+// WithByteaOutput sets how the server formats bytea values as text
+// (the bytea_output parameter): "hex" or "escape".
+// The default is "hex", but setting it explicitly keeps tests that compare
+// the textual form of binary data stable across servers.
+func WithByteaOutput(format string) StartOption {
+	return func(cfg *startConfig) {
+		if format != "hex" && format != "escape" {
+			cfg.setErr(fmt.Errorf("bytea output format %q is not \"hex\" or \"escape\"", format))
+			return
+		}
+		cfg.settings["bytea_output"] = format
+	}
+}
+
+// WithClientEncoding sets the character set the server uses
+// for connections that do not request one (the client_encoding parameter).
+// Many drivers request an encoding when they connect, overriding the setting:
+// github.com/lib/pq always uses UTF8.
+func WithClientEncoding(encoding string) StartOption {
+	return WithConfig("client_encoding", encoding)
+}
+
+// WithDefaultTablespace sets the tablespace in which tables and indexes
+// are created when a statement does not name one
+// (the default_tablespace parameter).
+// The tablespace does not exist in a new server:
+// create it with Server.CreateTablespace before creating objects.
+func WithDefaultTablespace(name string) StartOption {
+	return WithConfig("default_tablespace", name)
+}
+
+// WithTempTablespaces sets the tablespaces in which temporary tables
+// and the temporary files of sorts and hashes are created
+// (the temp_tablespaces parameter).
+// As with WithDefaultTablespace, the tablespaces must be created
+// with Server.CreateTablespace.
+func WithTempTablespaces(names ...string) StartOption {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdentifier(name)
+	}
+	return WithConfig("temp_tablespaces", strings.Join(quoted, ", "))
+}
+
+// WithDeadlockTimeout sets how long a query waits on a lock
+// before the server checks for a deadlock (the deadlock_timeout parameter).
+// The default is 1 second, which slows down tests that deadlock on purpose.
+// d is rounded down to a whole number of milliseconds
+// and must be at least 1 millisecond.
+func WithDeadlockTimeout(d time.Duration) StartOption {
+	return func(cfg *startConfig) {
+		ms := d / time.Millisecond
+		if ms < 1 {
+			cfg.setErr(fmt.Errorf("deadlock timeout %v is less than 1ms", d))
+			return
+		}
+		cfg.settings["deadlock_timeout"] = strconv.FormatInt(int64(ms), 10) + "ms"
+	}
+}
+
+// WithTCPKeepalives sets how the server detects dead TCP connections:
+// after a connection has been idle for idle seconds, the server sends
+// a keepalive every interval seconds and drops the connection after count
+// of them go unanswered (the tcp_keepalives_idle, tcp_keepalives_interval,
+// and tcp_keepalives_count parameters). Zero uses the operating system's
+// default. The settings only apply to TCP connections,
+// like those from WithChannelBinding; SHOW reports 0 on Unix sockets.
+func WithTCPKeepalives(idle, interval, count int) StartOption {
+	return func(cfg *startConfig) {
+		cfg.settings["tcp_keepalives_idle"] = strconv.Itoa(idle)
+		cfg.settings["tcp_keepalives_interval"] = strconv.Itoa(interval)
+		cfg.settings["tcp_keepalives_count"] = strconv.Itoa(count)
+	}
+}
+
+// WithParallelWorkers configures the server to run queries
+// with up to n parallel workers and lowers the planner's parallel costs
+// and table size thresholds to zero, so that the planner chooses
+// parallel plans even on the small tables typical of tests.
+// n must be positive. WithParallelWorkers requires PostgreSQL 10 or later.
+func WithParallelWorkers(n int) StartOption {
+	return func(cfg *startConfig) {
+		if n <= 0 {
+			cfg.setErr(fmt.Errorf("parallel workers must be positive (got %d)", n))
+			return
+		}
+		// Parallel workers are background workers,
+		// so leave room for the default 8 other background workers.
+		cfg.settings["max_worker_processes"] = strconv.Itoa(n + 8)
+		cfg.settings["max_parallel_workers"] = strconv.Itoa(n)
+		cfg.settings["max_parallel_workers_per_gather"] = strconv.Itoa(n)
+		cfg.settings["parallel_setup_cost"] = "0"
+		cfg.settings["parallel_tuple_cost"] = "0"
+		cfg.settings["min_parallel_table_scan_size"] = "0"
+		cfg.settings["min_parallel_index_scan_size"] = "0"
+	}
+}
+
+// plannerCostParams is the set of parameters WithPlannerCosts accepts.
+var plannerCostParams = map[string]bool{
+	"seq_page_cost":        true,
+	"random_page_cost":     true,
+	"cpu_tuple_cost":       true,
+	"cpu_index_tuple_cost": true,
+	"cpu_operator_cost":    true,
+	"parallel_setup_cost":  true,
+	"parallel_tuple_cost":  true,
+	"effective_cache_size": true,
+}
+
+// WithPlannerCosts sets the query planner's cost parameters,
+// like random_page_cost or effective_cache_size,
+// so that tests of query plans choose the same plans on every machine.
+// The keys of costs must be cost parameters: Start returns an error
+// for other parameters, which can be set with WithConfig instead.
+func WithPlannerCosts(costs map[string]string) StartOption {
+	return func(cfg *startConfig) {
+		for k, v := range costs {
+			if !plannerCostParams[k] {
+				cfg.setErr(fmt.Errorf("%q is not a planner cost parameter", k))
+				continue
+			}
+			cfg.settings[k] = v
+		}
+	}
+}
+
+// WithTempFileLimit sets the most disk space in kilobytes
+// that a single process can use for temporary files, like those used
+// by sorts and hashes that do not fit in work_mem
+// (the temp_file_limit parameter). Queries that exceed the limit fail,
+// which can catch queries that unexpectedly start spilling to disk.
+// The default is -1, which means no limit.
+func WithTempFileLimit(kb int) StartOption {
+	return WithConfig("temp_file_limit", strconv.Itoa(kb))
+}
+
+// WithFastStartup configures the server to avoid disk I/O that is unnecessary
+// for a short-lived server: checkpoints are effectively disabled and the
+// background writer is turned off.
+func WithFastStartup() StartOption {
+	return func(cfg *startConfig) {
+		cfg.settings["checkpoint_timeout"] = "1d"
+		cfg.settings["max_wal_size"] = "10GB"
+		cfg.settings["bgwriter_lru_maxpages"] = "0"
+	}
+}
+
+// A Profile is a named set of options for a common kind of test.
+type Profile int
+
+// Profiles for WithProfile.
+const (
+	// ProfileFast favors speed over durability. It uses the default
+	// non-durable settings and the settings of WithFastStartup.
+	ProfileFast Profile = 1 + iota
+	// ProfileProdLike uses PostgreSQL's durable defaults,
+	// for tests whose behavior depends on durability,
+	// like tests of crash recovery or write performance.
+	// fsync, synchronous_commit, full_page_writes, and autovacuum are on.
+	ProfileProdLike
+	// ProfileReplication configures the server for logical and physical
+	// replication, like WithReplication, but with wal_level set to logical
+	// so that tests can create logical replication slots.
+	ProfileReplication
+)
+
+// String returns the profile's name, like "ProfileFast".
+func (p Profile) String() string {
+	switch p {
+	case ProfileFast:
+		return "ProfileFast"
+	case ProfileProdLike:
+		return "ProfileProdLike"
+	case ProfileReplication:
+		return "ProfileReplication"
+	default:
+		return fmt.Sprintf("Profile(%d)", int(p))
+	}
+}
+
+// WithProfile applies the options of the given profile.
+// Options given after WithProfile override the profile's settings.
+func WithProfile(p Profile) StartOption {
+	return func(cfg *startConfig) {
+		switch p {
+		case ProfileFast:
+			cfg.settings["fsync"] = "off"
+			cfg.settings["synchronous_commit"] = "off"
+			cfg.settings["full_page_writes"] = "off"
+			WithFastStartup()(cfg)
+		case ProfileProdLike:
+			cfg.settings["fsync"] = "on"
+			cfg.settings["synchronous_commit"] = "on"
+			cfg.settings["full_page_writes"] = "on"
+			cfg.settings["autovacuum"] = "on"
+		case ProfileReplication:
+			WithReplication()(cfg)
+			cfg.settings["wal_level"] = "logical"
+			cfg.settings["max_replication_slots"] = "10"
+		default:
+			cfg.setErr(fmt.Errorf("unknown profile %v", p))
+		}
+	}
+}
@@ -0,0 +1,1229 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithAdminConn(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	var opened []string
+	srv, err := Start(ctx, WithAdminConn(func(dsn string) (AdminConn, error) {
+		opened = append(opened, dsn)
+		return sql.Open("postgres", dsn)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	if len(opened) != 1 {
+		t.Errorf("admin connections opened = %q; want 1 connection", opened)
+	}
+	if _, err := srv.CreateDatabase(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithDirPerm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permissions not supported on Windows")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithDirPerm(0750))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	for _, dir := range []string{srv.dir, filepath.Join(srv.dir, "data")} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got, want := info.Mode().Perm(), os.FileMode(0750); got != want {
+			t.Errorf("%s mode = %v; want %v", dir, got, want)
+		}
+	}
+}
+
+func TestWithTempDir(t *testing.T) {
+	parent := makeTempDir(t)
+	t.Run("Name", func(t *testing.T) {
+		cfg := newStartConfig([]StartOption{WithTempDir(parent, func() string { return "ci-pg-1" })})
+		dir, err := cfg.newServerDir()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(parent, "ci-pg-1"); dir != want {
+			t.Errorf("dir = %q; want %q", dir, want)
+		}
+		if _, err := cfg.newServerDir(); err == nil {
+			t.Error("Creating directory with same name twice did not return an error")
+		}
+	})
+	t.Run("DefaultName", func(t *testing.T) {
+		cfg := newStartConfig([]StartOption{WithTempDir(parent, nil)})
+		dir, err := cfg.newServerDir()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := filepath.Dir(dir); got != parent {
+			t.Errorf("dir = %q; want inside %q", dir, parent)
+		}
+		if base := filepath.Base(dir); !strings.HasPrefix(base, "postgrestest") {
+			t.Errorf("dir = %q; want name to start with %q", dir, "postgrestest")
+		}
+	})
+}
+
+func TestConfigHash(t *testing.T) {
+	base := ConfigHash()
+	if got := ConfigHash(); got != base {
+		t.Errorf("ConfigHash() = %q, then %q; want stable", base, got)
+	}
+	if got := ConfigHash(WithFastStartup()); got != base {
+		t.Errorf("ConfigHash(WithFastStartup()) = %q; want %q (same as no options)", got, base)
+	}
+	if got := ConfigHash(WithDirPerm(0750)); got == base {
+		t.Errorf("ConfigHash(WithDirPerm(0750)) = %q; want different from no options", got)
+	}
+	if got := ConfigHash(WithWALSegSize(64)); got == base {
+		t.Errorf("ConfigHash(WithWALSegSize(64)) = %q; want different from no options", got)
+	}
+	if got := ConfigHash(WithExtensionDir("/opt/ext")); got == base {
+		t.Errorf("ConfigHash(WithExtensionDir(\"/opt/ext\")) = %q; want different from no options", got)
+	}
+	if cfg := newStartConfig(nil); cfg.hash("16") == cfg.hash("17") {
+		t.Error("hash(\"16\") = hash(\"17\"); want different for different PostgreSQL versions")
+	}
+	setenv(t, "LC_ALL", "xx_XX.ISO-8859-1")
+	if got := ConfigHash(); got == base {
+		t.Errorf("ConfigHash() with LC_ALL changed = %q; want different", got)
+	}
+}
+
+func TestWithWALSegSize(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		for _, mb := range []int{0, 3, 2048} {
+			_, err := Start(context.Background(), WithWALSegSize(mb))
+			if err == nil {
+				t.Errorf("Start(ctx, WithWALSegSize(%d)) did not return an error", mb)
+			}
+		}
+	})
+	t.Run("Server", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+		defer cancel()
+		srv, err := Start(ctx, WithWALSegSize(64))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(srv.Cleanup)
+		var got string
+		if err := srv.conn.QueryRowContext(ctx, "SHOW wal_segment_size;").Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if want := "64MB"; got != want {
+			t.Errorf("wal_segment_size = %q; want %q", got, want)
+		}
+	})
+}
+
+func TestWithDatabases(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithDatabases("app", "analytics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	for _, dbName := range []string{"app", "analytics"} {
+		db, err := sql.Open("postgres", srv.DSN(dbName))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var got string
+		if err := db.QueryRowContext(ctx, `SELECT current_database();`).Scan(&got); err != nil {
+			t.Errorf("connect to %q: %v", dbName, err)
+		} else if got != dbName {
+			t.Errorf("current_database() = %q; want %q", got, dbName)
+		}
+		db.Close()
+	}
+}
+
+func TestWithConfigFunc(t *testing.T) {
+	cfg := newStartConfig([]StartOption{
+		WithConfig("work_mem", "64MB"),
+		WithConfigFunc(func(settings map[string]string) map[string]string {
+			if got, want := settings["work_mem"], "64MB"; got != want {
+				t.Errorf("settings[%q] = %q; want %q", "work_mem", got, want)
+			}
+			delete(settings, "fsync")
+			settings["application_name"] = "it's a test"
+			return settings
+		}),
+	})
+	dataDir := makeTempDir(t)
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeConfig(dataDir, "/tmp/socket", 0, cfg); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := ioutil.ReadFile(filepath.Join(dataDir, "postgresql.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(conf), "\n"), "\n")
+	for _, want := range []string{
+		"work_mem = '64MB'",
+		"application_name = 'it''s a test'",
+		"unix_socket_directories = '/tmp/socket'",
+	} {
+		if !containsString(lines, want) {
+			t.Errorf("postgresql.conf does not contain %q. Content:\n%s", want, conf)
+		}
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "fsync ") {
+			t.Errorf("postgresql.conf contains %q", line)
+		}
+	}
+}
+
+func TestWithConfigFile(t *testing.T) {
+	confDir := makeTempDir(t)
+	confPath := filepath.Join(confDir, "prod.conf")
+	const prodConf = "# Production settings\n" +
+		"work_mem = '12MB'\n" +
+		"fsync = on\n" +
+		"listen_addresses = '*'\n"
+	if err := ioutil.WriteFile(confPath, []byte(prodConf), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Write", func(t *testing.T) {
+		transcript := new(bytes.Buffer)
+		cfg := newStartConfig([]StartOption{
+			WithConfig("fsync", "off"),
+			WithTranscript(transcript),
+			WithConfigFile(confPath),
+			WithConfig("application_name", "xyzzy"),
+		})
+		dataDir := makeTempDir(t)
+		if err := ioutil.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("16\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeConfig(dataDir, "/tmp/socket", 0, cfg); err != nil {
+			t.Fatal(err)
+		}
+		conf, err := ioutil.ReadFile(filepath.Join(dataDir, "postgresql.conf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(conf), "\n"), "\n")
+		if want := "include '" + filepath.ToSlash(confPath) + "'"; len(lines) == 0 || lines[0] != want {
+			t.Errorf("postgresql.conf does not start with %q. Content:\n%s", want, conf)
+		}
+		for _, want := range []string{
+			"fsync = 'off'",
+			"application_name = 'xyzzy'",
+			"listen_addresses = ''",
+		} {
+			if !containsString(lines, want) {
+				t.Errorf("postgresql.conf does not contain %q. Content:\n%s", want, conf)
+			}
+		}
+		for _, line := range lines {
+			if strings.HasPrefix(line, "synchronous_commit ") || strings.HasPrefix(line, "log_line_prefix ") || strings.HasPrefix(line, "jit ") {
+				t.Errorf("postgresql.conf contains %q", line)
+			}
+		}
+		if got := transcript.String(); !strings.Contains(got, "listen_addresses") {
+			t.Errorf("transcript = %q; want warning about listen_addresses", got)
+		}
+	})
+
+	t.Run("NoTranscript", func(t *testing.T) {
+		cfg := newStartConfig([]StartOption{WithConfigFile(confPath)})
+		warnings := new(bytes.Buffer)
+		cfg.warnOutput = warnings
+		dataDir := makeTempDir(t)
+		if err := ioutil.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("16\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeConfig(dataDir, "/tmp/socket", 0, cfg); err != nil {
+			t.Fatal(err)
+		}
+		if got := warnings.String(); !strings.Contains(got, "listen_addresses") {
+			t.Errorf("warnings = %q; want warning about listen_addresses", got)
+		}
+	})
+
+	t.Run("Server", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+		defer cancel()
+		srv, err := Start(ctx, WithConfigFile(confPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(srv.Cleanup)
+		for name, want := range map[string]string{"work_mem": "12MB", "fsync": "on"} {
+			var got string
+			if err := srv.conn.QueryRowContext(ctx, "SHOW "+name+";").Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("%s = %q; want %q", name, got, want)
+			}
+		}
+	})
+}
+
+func TestWithClusterName(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []StartOption
+		want string
+	}{
+		{name: "Default", want: "cluster_name = 'postgrestest123'"},
+		{name: "Set", opts: []StartOption{WithClusterName("myapp")}, want: "cluster_name = 'myapp'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dataDir := makeTempDir(t)
+			if err := ioutil.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("16\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := writeConfig(dataDir, "/tmp/postgrestest123", 0, newStartConfig(test.opts)); err != nil {
+				t.Fatal(err)
+			}
+			conf, err := ioutil.ReadFile(filepath.Join(dataDir, "postgresql.conf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !containsString(strings.Split(string(conf), "\n"), test.want) {
+				t.Errorf("postgresql.conf does not contain %q. Content:\n%s", test.want, conf)
+			}
+		})
+	}
+}
+
+func TestWithMinVersion(t *testing.T) {
+	tests := []struct {
+		minVersion   int
+		majorVersion int
+		wantErr      bool
+	}{
+		{minVersion: 14, majorVersion: 16, wantErr: false},
+		{minVersion: 16, majorVersion: 16, wantErr: false},
+		{minVersion: 14, majorVersion: 12, wantErr: true},
+	}
+	for _, test := range tests {
+		cfg := newStartConfig([]StartOption{WithMinVersion(test.minVersion)})
+		_, err := cfg.serverSettings(test.majorVersion)
+		if err != nil && !test.wantErr {
+			t.Errorf("WithMinVersion(%d) on PostgreSQL %d: %v", test.minVersion, test.majorVersion, err)
+		}
+		if err == nil && test.wantErr {
+			t.Errorf("WithMinVersion(%d) on PostgreSQL %d did not return an error", test.minVersion, test.majorVersion)
+		}
+	}
+}
+
+func TestWithJIT(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []StartOption
+		majorVersion int
+		want         string // empty if unset
+	}{
+		{name: "Default", majorVersion: 16, want: "off"},
+		{name: "Enabled", opts: []StartOption{WithJIT(true)}, majorVersion: 16, want: "on"},
+		{name: "WithConfig", opts: []StartOption{WithConfig("jit", "on")}, majorVersion: 16, want: "on"},
+		{name: "Unsupported", opts: []StartOption{WithJIT(true)}, majorVersion: 10, want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings, err := newStartConfig(test.opts).serverSettings(test.majorVersion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := settings["jit"]; got != test.want {
+				t.Errorf("settings[%q] = %q; want %q", "jit", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWithLogConnections(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithLogConnections())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := sql.Open("postgres", srv.DefaultDatabase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// The backend logs the disconnection as it exits,
+	// which may be after Close returns.
+	for {
+		log, err := ioutil.ReadFile(srv.LogFile())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(log, []byte("connection authorized")) && bytes.Contains(log, []byte("disconnection:")) {
+			return
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatalf("Log does not record connection and disconnection. Content:\n%s", log)
+		}
+	}
+}
+
+func TestWithGEQO(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithGEQO(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var got string
+	if err := db.QueryRowContext(ctx, "SHOW geqo;").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "off"; got != want {
+		t.Errorf("geqo = %q; want %q", got, want)
+	}
+
+	// Join more tables than geqo_threshold (12 by default).
+	const numTables = 14
+	query := new(strings.Builder)
+	query.WriteString("SELECT count(*) FROM t0")
+	for i := 0; i < numTables; i++ {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE t%d AS SELECT i FROM generate_series(1, %d) i;`, i, 10*(i+1))); err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 {
+			fmt.Fprintf(query, " JOIN t%d ON t%d.i = t%d.i", i, i, i-1)
+		}
+	}
+	if _, err := db.ExecContext(ctx, `ANALYZE;`); err != nil {
+		t.Fatal(err)
+	}
+	var plans [2]string
+	for i := range plans {
+		err := db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query.String()).Scan(&plans[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if plans[0] != plans[1] {
+		t.Errorf("plans differ between runs:\n%s\n%s", plans[0], plans[1])
+	}
+}
+
+func TestWithAutovacuumNaptime(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := Start(context.Background(), WithAutovacuumNaptime(500*time.Millisecond)); err == nil {
+			t.Error("Start with naptime less than 1s did not return an error")
+		}
+	})
+	t.Run("Server", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+		defer cancel()
+		srv, err := Start(ctx, WithAutovacuumNaptime(2*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(srv.Cleanup)
+		var got string
+		if err := srv.conn.QueryRowContext(ctx, "SHOW autovacuum_naptime;").Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if want := "2s"; got != want {
+			t.Errorf("autovacuum_naptime = %q; want %q", got, want)
+		}
+	})
+}
+
+func TestWithReadinessBackoff(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		tests := []struct {
+			initial, max time.Duration
+		}{
+			{0, 25 * time.Millisecond},
+			{-time.Millisecond, 25 * time.Millisecond},
+			{10 * time.Millisecond, time.Millisecond},
+		}
+		for _, test := range tests {
+			if _, err := Start(context.Background(), WithReadinessBackoff(test.initial, test.max)); err == nil {
+				t.Errorf("Start with WithReadinessBackoff(%v, %v) did not return an error", test.initial, test.max)
+			}
+		}
+	})
+	t.Run("Config", func(t *testing.T) {
+		cfg := newStartConfig([]StartOption{WithReadinessBackoff(5*time.Millisecond, 100*time.Millisecond)})
+		if cfg.err != nil {
+			t.Fatal(cfg.err)
+		}
+		if cfg.readinessInitial != 5*time.Millisecond || cfg.readinessMax != 100*time.Millisecond {
+			t.Errorf("readiness backoff = %v, %v; want 5ms, 100ms", cfg.readinessInitial, cfg.readinessMax)
+		}
+	})
+	t.Run("Server", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+		defer cancel()
+		srv, err := Start(ctx, WithReadinessBackoff(5*time.Millisecond, 100*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(srv.Cleanup)
+		if err := srv.conn.PingContext(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestWithTrackIOTiming(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithTrackIOTiming())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	plan, err := srv.ExplainJSON(ctx, srv.DefaultDatabase(), "SELECT count(*) FROM pg_class;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// PostgreSQL 17 renamed "I/O Read Time" to "Shared I/O Read Time".
+	if want := "I/O Read Time"; !bytes.Contains(plan, []byte(want)) {
+		t.Errorf("plan does not contain %q:\n%s", want, plan)
+	}
+}
+
+func TestWithLogLinePrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []StartOption
+		prefix func(dbName string) string
+	}{
+		{
+			name: "Default",
+			prefix: func(dbName string) string {
+				return " " + dbName + " LOG:"
+			},
+		},
+		{
+			name: "Custom",
+			opts: []StartOption{WithLogLinePrefix("xyzzy %d: ")},
+			prefix: func(dbName string) string {
+				return "xyzzy " + dbName + ": LOG:"
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+			defer cancel()
+			srv, err := Start(ctx, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(srv.Cleanup)
+			dsn, err := srv.CreateDatabase(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dbName := strings.TrimPrefix(mustParseURL(t, dsn).Path, "/")
+			db, err := sql.Open("postgres", dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if _, err := db.ExecContext(ctx, `DO $$ BEGIN RAISE LOG 'postgrestest marker'; END $$;`); err != nil {
+				t.Fatal(err)
+			}
+			log, err := ioutil.ReadFile(srv.LogFile())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := test.prefix(dbName) + "  postgrestest marker"
+			if !bytes.Contains(log, []byte(want)) {
+				t.Errorf("log does not contain %q. Content:\n%s", want, log)
+			}
+		})
+	}
+}
+
+func TestWithAutovacuum(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithAutovacuum(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	var got string
+	if err := srv.conn.QueryRowContext(ctx, "SHOW autovacuum;").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "off"; got != want {
+		t.Errorf("autovacuum = %q; want %q", got, want)
+	}
+}
+
+func TestWithMaxPreparedTransactions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithMaxPreparedTransactions(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := srv.NewDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN;"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "PREPARE TRANSACTION 'xyzzy';"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT PREPARED 'xyzzy';"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithMaxLocksPerTransaction(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithMaxLocksPerTransaction(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := srv.NewDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Creating thousands of tables in one transaction locks each of them,
+	// which exceeds the default lock table size.
+	_, err = db.ExecContext(ctx, `DO $$
+BEGIN
+	FOR i IN 1..8000 LOOP
+		EXECUTE format('CREATE TABLE t%s (id INTEGER)', i);
+	END LOOP;
+END
+$$;`)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithByteaOutput(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := Start(context.Background(), WithByteaOutput("base64")); err == nil {
+			t.Error("Start with invalid bytea output did not return an error")
+		}
+	})
+	t.Run("Server", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+		defer cancel()
+		srv, err := Start(ctx, WithByteaOutput("escape"), WithClientEncoding("LATIN1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(srv.Cleanup)
+		db, err := srv.NewDatabase(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if err := db.QueryRowContext(ctx, `SELECT '\x41ff'::bytea::text;`).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if want := `A\377`; got != want {
+			t.Errorf("bytea as text = %q; want %q", got, want)
+		}
+		// lib/pq requests UTF8 when it connects,
+		// so check the server's configured default instead.
+		err = db.QueryRowContext(ctx, `SELECT setting FROM pg_file_settings WHERE name = 'client_encoding';`).Scan(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "LATIN1"; got != want {
+			t.Errorf("client_encoding in configuration = %q; want %q", got, want)
+		}
+	})
+}
+
+func TestWithDeadlockTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithDeadlockTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := srv.NewDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE TABLE foo (id INTEGER PRIMARY KEY); INSERT INTO foo VALUES (1), (2);`); err != nil {
+		t.Fatal(err)
+	}
+	tx1, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx1.Rollback()
+	tx2, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx2.Rollback()
+	if _, err := tx1.ExecContext(ctx, `UPDATE foo SET id = id WHERE id = 1;`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx2.ExecContext(ctx, `UPDATE foo SET id = id WHERE id = 2;`); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	errc := make(chan error, 2)
+	go func() {
+		_, err := tx1.ExecContext(ctx, `UPDATE foo SET id = id WHERE id = 2;`)
+		errc <- err
+	}()
+	go func() {
+		_, err := tx2.ExecContext(ctx, `UPDATE foo SET id = id WHERE id = 1;`)
+		errc <- err
+	}()
+	// One transaction is aborted to break the deadlock,
+	// which releases its locks and lets the other proceed.
+	var deadlocked bool
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			if !strings.Contains(err.Error(), "deadlock") {
+				t.Error(err)
+			}
+			deadlocked = true
+		}
+	}
+	if !deadlocked {
+		t.Error("deadlock not detected")
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("deadlock took %v to detect; want less than the default 1s", elapsed)
+	}
+}
+
+func TestWithTCPKeepalives(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithTCPKeepalives(30, 5, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	want := map[string]string{
+		"tcp_keepalives_idle":     "30",
+		"tcp_keepalives_interval": "5",
+		"tcp_keepalives_count":    "3",
+	}
+	for name, want := range want {
+		// SHOW reports 0 for connections over a Unix socket,
+		// so check the configured value instead.
+		var got string
+		err := srv.conn.QueryRowContext(ctx, `SELECT reset_val FROM pg_settings WHERE name = $1;`, name).Scan(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s = %q; want %q", name, got, want)
+		}
+	}
+}
+
+func TestWithParallelWorkers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithParallelWorkers(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := srv.NewDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE TABLE foo AS SELECT i FROM generate_series(1, 1000) i;`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `ANALYZE foo;`); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.QueryContext(ctx, `EXPLAIN SELECT count(*) FROM foo;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			t.Fatal(err)
+		}
+		plan = append(plan, line)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(plan, "\n"); !strings.Contains(got, "Gather") {
+		t.Errorf("plan does not use Gather:\n%s", got)
+	}
+}
+
+func TestWithPlannerCosts(t *testing.T) {
+	costs := map[string]string{
+		"random_page_cost":     "1.1",
+		"effective_cache_size": "1GB",
+	}
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := Start(context.Background(), WithPlannerCosts(map[string]string{"work_mem": "1GB"})); err == nil {
+			t.Error("Start with non-cost parameter did not return an error")
+		}
+	})
+	t.Run("Server", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+		defer cancel()
+		srv, err := Start(ctx, WithPlannerCosts(costs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(srv.Cleanup)
+		for name, want := range costs {
+			var got string
+			if err := srv.conn.QueryRowContext(ctx, "SHOW "+name+";").Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("%s = %q; want %q", name, got, want)
+			}
+		}
+	})
+}
+
+func TestWithTempFileLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithTempFileLimit(1024), WithConfig("work_mem", "64kB"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := srv.NewDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sorting a million integers needs far more than 1 MB of temporary files.
+	var n int
+	err = db.QueryRowContext(ctx, `SELECT count(*) FROM (SELECT i FROM generate_series(1, 1000000) i ORDER BY i DESC) sorted;`).Scan(&n)
+	const want = "temp_file_limit"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Large sort error = %v; want to mention %q", err, want)
+	}
+}
+
+func TestWithProfile(t *testing.T) {
+	tests := []struct {
+		profile Profile
+		want    map[string]string
+	}{
+		{
+			profile: ProfileFast,
+			want: map[string]string{
+				"fsync":                 "off",
+				"checkpoint_timeout":    "1d",
+				"bgwriter_lru_maxpages": "0",
+			},
+		},
+		{
+			profile: ProfileProdLike,
+			want: map[string]string{
+				"fsync":              "on",
+				"synchronous_commit": "on",
+				"full_page_writes":   "on",
+				"autovacuum":         "on",
+			},
+		},
+		{
+			profile: ProfileReplication,
+			want: map[string]string{
+				"wal_level":       "logical",
+				"max_wal_senders": "10",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.profile.String(), func(t *testing.T) {
+			cfg := newStartConfig([]StartOption{WithProfile(test.profile)})
+			if cfg.err != nil {
+				t.Fatal(cfg.err)
+			}
+			for k, want := range test.want {
+				if got := cfg.settings[k]; got != want {
+					t.Errorf("settings[%q] = %q; want %q", k, got, want)
+				}
+			}
+		})
+	}
+	if _, err := Start(context.Background(), WithProfile(Profile(42))); err == nil {
+		t.Error("Start with unknown profile did not return an error")
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWithMessagesLocale(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithMessagesLocale("C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := sql.Open("postgres", srv.DefaultDatabase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.ExecContext(ctx, `SELECT * FROM bork;`)
+	if err == nil {
+		t.Fatal("Query on missing table did not return an error")
+	}
+	const want = `relation "bork" does not exist`
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %q; want to contain %q", err, want)
+	}
+}
+
+func TestWithPreserveOnFailure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	var dir string
+	t.Run("Pass", func(t *testing.T) {
+		srv, err := Start(ctx, WithPreserveOnFailure(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir = srv.dir
+		t.Cleanup(srv.Cleanup)
+	})
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("os.Stat(%q) = _, %v after passing test; want not exist", dir, err)
+	}
+}
+
+func TestWithSSLMode(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := Start(context.Background(), WithSSLMode("bogus")); err == nil {
+			t.Error("Start with invalid sslmode did not return an error")
+		}
+	})
+	t.Run("Default", func(t *testing.T) {
+		if got, want := newStartConfig(nil).dsnSSLMode(), "disable"; got != want {
+			t.Errorf("default sslmode = %q; want %q", got, want)
+		}
+		if got, want := newStartConfig([]StartOption{WithChannelBinding()}).dsnSSLMode(), "require"; got != want {
+			t.Errorf("sslmode with WithChannelBinding = %q; want %q", got, want)
+		}
+		opts := []StartOption{WithChannelBinding(), WithSSLMode("disable")}
+		if got, want := newStartConfig(opts).dsnSSLMode(), "disable"; got != want {
+			t.Errorf("sslmode with WithChannelBinding and WithSSLMode(%q) = %q; want %q", want, got, want)
+		}
+	})
+	tests := []struct {
+		mode    string
+		opts    []StartOption
+		wantSSL bool
+	}{
+		{mode: "disable", wantSSL: false},
+		{mode: "prefer", wantSSL: false},
+		{mode: "require", wantSSL: true},
+		{mode: "verify-full", wantSSL: true},
+		{mode: "require", opts: []StartOption{WithChannelBinding()}, wantSSL: true},
+	}
+	for _, test := range tests {
+		name := test.mode
+		if len(test.opts) > 0 {
+			name += "/ChannelBinding"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+			defer cancel()
+			srv, err := Start(ctx, append([]StartOption{WithSSLMode(test.mode)}, test.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(srv.Cleanup)
+			if got := mustParseURL(t, srv.DefaultDatabase()).Query().Get("sslmode"); got != test.mode {
+				t.Errorf("sslmode in %q = %q; want %q", srv.DefaultDatabase(), got, test.mode)
+			}
+			db, err := srv.NewDatabase(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ssl bool
+			err = db.QueryRowContext(ctx, `SELECT coalesce(ssl, false) FROM pg_stat_ssl WHERE pid = pg_backend_pid();`).Scan(&ssl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ssl != test.wantSSL {
+				t.Errorf("connection uses TLS = %t; want %t", ssl, test.wantSSL)
+			}
+		})
+	}
+}
+
+func TestWithDSNParams(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	params := map[string]string{
+		"application_name": "my app&co",
+		"connect_timeout":  "10",
+	}
+	srv, err := Start(ctx, WithDSNParams(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dsn := range []string{srv.DefaultDatabase(), dsn} {
+		q := mustParseURL(t, dsn).Query()
+		for k, want := range params {
+			if got := q.Get(k); got != want {
+				t.Errorf("%s in %q = %q; want %q", k, dsn, got, want)
+			}
+		}
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var appName string
+	if err := db.QueryRowContext(ctx, `SHOW application_name;`).Scan(&appName); err != nil {
+		t.Fatal(err)
+	}
+	if want := params["application_name"]; appName != want {
+		t.Errorf("application_name = %q; want %q", appName, want)
+	}
+}
+
+func TestWithProcessLog(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	tb := &logRecorder{T: t}
+	srv, err := Start(ctx, WithProcessLog(tb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	// pg_ctl prints this once it has launched the postmaster,
+	// but its output may be copied after the server accepts connections.
+	const want = "pg_ctl: server starting"
+	for !containsString(tb.logs(), want) {
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatalf("logged %q; want to include %q", tb.logs(), want)
+		}
+	}
+}
+
+// logRecorder is a TB that records the lines passed to Log
+// in addition to logging them to the test.
+type logRecorder struct {
+	*testing.T
+
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *logRecorder) Log(args ...interface{}) {
+	r.T.Helper()
+	r.mu.Lock()
+	r.lines = append(r.lines, fmt.Sprint(args...))
+	r.mu.Unlock()
+	r.T.Log(args...)
+}
+
+func (r *logRecorder) logs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.lines...)
+}
+
+func TestWithInitSQLReader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	opt := WithInitSQLReader(strings.NewReader(`CREATE TABLE foo (id INTEGER PRIMARY KEY);
+INSERT INTO foo VALUES (1), (2);`))
+	// Start twice to check that the option can be reused.
+	for i := 0; i < 2; i++ {
+		srv, err := Start(ctx, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(srv.Cleanup)
+		var n int
+		if err := srv.conn.QueryRowContext(ctx, `SELECT count(*) FROM foo;`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("Server #%d: foo has %d rows; want 2", i+1, n)
+		}
+	}
+}
+
+func TestWithTranscript(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	transcript := new(bytes.Buffer)
+	srv, err := Start(ctx, WithTranscript(transcript))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Cleanup()
+	lines := strings.Split(transcript.String(), "\n")
+	for _, prefix := range []string{"initdb: ", "postgres: "} {
+		found := false
+		for _, line := range lines {
+			if strings.HasPrefix(line, prefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Transcript has no lines starting with %q. Content:\n%s", prefix, transcript)
+		}
+	}
+}
+
+func TestTranscriptFlush(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tr := &transcript{w: buf}
+	w := tr.phase("postgres: ")
+	io.WriteString(w, "complete\npartial")
+	if got, want := buf.String(), "postgres: complete\n"; got != want {
+		t.Errorf("before flush, transcript = %q; want %q", got, want)
+	}
+	flushTranscript(w)
+	if got, want := buf.String(), "postgres: complete\npostgres: partial\n"; got != want {
+		t.Errorf("after flush, transcript = %q; want %q", got, want)
+	}
+}
+
+func TestWithExtensionDir(t *testing.T) {
+	cfg := newStartConfig([]StartOption{WithExtensionDir("/opt/myext")})
+	if _, err := cfg.serverSettings(17); err == nil {
+		t.Error("serverSettings(17) did not return an error")
+	}
+	settings, err := cfg.serverSettings(18)
+	if err != nil {
+		t.Fatal("serverSettings(18):", err)
+	}
+	sep := string(filepath.ListSeparator)
+	if got, want := settings["extension_control_path"], "$system"+sep+"/opt/myext"; got != want {
+		t.Errorf("extension_control_path = %q; want %q", got, want)
+	}
+	if got, want := settings["dynamic_library_path"], "$libdir"+sep+"/opt/myext"; got != want {
+		t.Errorf("dynamic_library_path = %q; want %q", got, want)
+	}
+}
+
+func TestWithWarmup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithWarmup(`CREATE TABLE warm (id SERIAL PRIMARY KEY);`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	var n int
+	if err := srv.conn.QueryRowContext(ctx, `SELECT count(*) FROM warm;`).Scan(&n); err != nil {
+		t.Error("Warmup statement was not run:", err)
+	}
+}
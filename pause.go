@@ -0,0 +1,109 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Pause stops the server's processes without terminating them,
+// so that the server stops responding but keeps its connections open,
+// like a server that has stalled. Queries and new connections hang
+// until Resume is called. This is useful for testing client timeouts.
+// Pause is only supported on Unix systems, where it sends SIGSTOP.
+// Calling Pause on a server that is already paused, or is being paused
+// by another goroutine, does nothing.
+//
+// While the server is paused, other methods of the server
+// that communicate with it will hang. Cleanup resumes the server first.
+func (srv *Server) Pause() error {
+	srv.mu.Lock()
+	if srv.pausedPIDs != nil || srv.pausing {
+		srv.mu.Unlock()
+		return nil
+	}
+	srv.pausing = true
+	srv.mu.Unlock()
+
+	// List the processes without holding the lock,
+	// so that the queries don't block other methods.
+	ctx, cancel := context.WithTimeout(context.Background(), pauseQueryTimeout)
+	defer cancel()
+	pids, err := srv.pausePIDs(ctx)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.pausing = false
+	if err != nil {
+		return fmt.Errorf("pause server: %w", err)
+	}
+	if err := signalProcesses(pids, sigStop); err != nil {
+		// Don't leave the server half-paused.
+		signalProcesses(pids, sigCont)
+		return fmt.Errorf("pause server: %w", err)
+	}
+	srv.pausedPIDs = pids
+	return nil
+}
+
+// pauseQueryTimeout is how long Pause waits for the server
+// to list its processes.
+const pauseQueryTimeout = 10 * time.Second
+
+// pausePIDs returns the processes to stop to pause the server:
+// the postmaster, then the backends, then the administrative connection's
+// backend, which must run last so that the queries here complete.
+func (srv *Server) pausePIDs(ctx context.Context) ([]int, error) {
+	rows, err := srv.conn.QueryContext(ctx, "SELECT pid FROM pg_stat_activity WHERE pid <> pg_backend_pid();")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pids := []int{srv.pid}
+	for rows.Next() {
+		var pid int
+		if err := rows.Scan(&pid); err != nil {
+			return nil, err
+		}
+		pids = append(pids, pid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var adminPID int
+	if err := srv.conn.QueryRowContext(ctx, "SELECT pg_backend_pid();").Scan(&adminPID); err != nil {
+		return nil, err
+	}
+	return append(pids, adminPID), nil
+}
+
+// Resume continues a server paused by Pause.
+// Resume does nothing if the server is not paused.
+func (srv *Server) Resume() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.pausedPIDs == nil {
+		return nil
+	}
+	if err := signalProcesses(srv.pausedPIDs, sigCont); err != nil {
+		return fmt.Errorf("resume server: %w", err)
+	}
+	srv.pausedPIDs = nil
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PoolConfig configures the pgbouncer started by StartPgBouncer.
+// The zero value uses pgbouncer's defaults.
+type PoolConfig struct {
+	// Database is the name of the database the returned data source name
+	// connects to. If empty, the default "postgres" database is used.
+	// Other databases on the server can be reached through pgbouncer
+	// by changing the data source name's path.
+	Database string
+	// PoolMode is when pgbouncer returns a server connection to the pool:
+	// "session", "transaction", or "statement".
+	// If empty, "session" is used.
+	PoolMode string
+	// DefaultPoolSize is the number of server connections
+	// pgbouncer opens per database and user. If zero, pgbouncer's default is used.
+	DefaultPoolSize int
+	// MaxClientConn is the number of client connections pgbouncer accepts.
+	// If zero, pgbouncer's default is used.
+	MaxClientConn int
+}
+
+// pgbouncerPort is the port in the name of pgbouncer's Unix socket.
+// pgbouncer's socket is in its own directory, so it cannot collide
+// with the server's socket.
+const pgbouncerPort = 6432
+
+// StartPgBouncer starts pgbouncer in front of the server
+// and returns a data source name that connects through it,
+// so tests can exercise an application through a connection pooler
+// as in production. pgbouncer is found like the PostgreSQL programs;
+// StartPgBouncer returns an error if it is not installed.
+// pgbouncer listens on a Unix socket and trusts all connections
+// from the roles that exist when StartPgBouncer is called.
+// The superuser can connect to pgbouncer's admin console
+// by using "pgbouncer" as the database name.
+//
+// The caller must call the returned cleanup function to stop pgbouncer
+// before calling Cleanup on srv.
+func (srv *Server) StartPgBouncer(ctx context.Context, pool PoolConfig) (dsn string, cleanup func(), err error) {
+	bouncer, err := command("pgbouncer")
+	if err != nil {
+		return "", nil, fmt.Errorf("start pgbouncer: %w", err)
+	}
+	dir, err := srv.cfg.newServerDir()
+	if err != nil {
+		return "", nil, fmt.Errorf("start pgbouncer: %w", checkDiskFull(err, srv.cfg.tempDirRoot()))
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+	confPath := filepath.Join(dir, "pgbouncer.ini")
+	if err := ioutil.WriteFile(confPath, []byte(pgbouncerConfig(srv, dir, pool)), 0600); err != nil {
+		return "", nil, fmt.Errorf("start pgbouncer: %w", err)
+	}
+	// With auth_type = trust, users still have to be listed in the auth file.
+	users, err := srv.roleNames(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("start pgbouncer: %w", err)
+	}
+	authFile := new(strings.Builder)
+	for _, user := range users {
+		fmt.Fprintf(authFile, "%s \"\"\n", pgbouncerQuote(user))
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "userlist.txt"), []byte(authFile.String()), 0600); err != nil {
+		return "", nil, fmt.Errorf("start pgbouncer: %w", err)
+	}
+
+	bouncer.Args = append(bouncer.Args, confPath)
+	if w := srv.cfg.transcriptPhase("pgbouncer: "); w != nil {
+		bouncer.Stdout = w
+		bouncer.Stderr = w
+	}
+	if err := bouncer.Start(); err != nil {
+		return "", nil, fmt.Errorf("start pgbouncer: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		bouncer.Wait()
+	}()
+	stop := func() {
+		bouncer.Process.Kill()
+		<-exited
+		flushTranscript(bouncer.Stdout)
+	}
+
+	u := *srv.baseURL
+	u.Path = pool.Database
+	if u.Path == "" {
+		u.Path = "postgres"
+	}
+	q := u.Query()
+	q.Set("host", dir)
+	q.Set("port", strconv.Itoa(pgbouncerPort))
+	q.Set("sslmode", "disable")
+	u.RawQuery = q.Encode()
+	dsn = dsnString(&u)
+	if err := srv.waitForPgBouncer(ctx, dsn, exited); err != nil {
+		stop()
+		logOutput, _ := ioutil.ReadFile(filepath.Join(dir, "pgbouncer.log"))
+		if len(logOutput) == 0 {
+			return "", nil, fmt.Errorf("start pgbouncer: %w", err)
+		}
+		return "", nil, fmt.Errorf("start pgbouncer: %w\n%s", err, logOutput)
+	}
+	cleanup = func() {
+		stop()
+		os.RemoveAll(dir)
+	}
+	return dsn, cleanup, nil
+}
+
+// pgbouncerConfig returns the contents of a pgbouncer.ini file
+// for a pgbouncer in front of srv that keeps its files in dir.
+func pgbouncerConfig(srv *Server, dir string, pool PoolConfig) string {
+	sb := new(strings.Builder)
+	sb.WriteString("[databases]\n")
+	fmt.Fprintf(sb, "* = host=%s port=%d\n", filepath.ToSlash(srv.dir), srv.port)
+	sb.WriteString("[pgbouncer]\n")
+	sb.WriteString("listen_addr =\n")
+	fmt.Fprintf(sb, "listen_port = %d\n", pgbouncerPort)
+	fmt.Fprintf(sb, "unix_socket_dir = %s\n", filepath.ToSlash(dir))
+	fmt.Fprintf(sb, "logfile = %s\n", filepath.ToSlash(filepath.Join(dir, "pgbouncer.log")))
+	sb.WriteString("auth_type = trust\n")
+	fmt.Fprintf(sb, "auth_file = %s\n", filepath.ToSlash(filepath.Join(dir, "userlist.txt")))
+	fmt.Fprintf(sb, "admin_users = %s\n", superuserName)
+	// Some drivers send extra_float_digits, which pgbouncer rejects by default.
+	sb.WriteString("ignore_startup_parameters = extra_float_digits\n")
+	if pool.PoolMode != "" {
+		fmt.Fprintf(sb, "pool_mode = %s\n", pool.PoolMode)
+	}
+	if pool.DefaultPoolSize > 0 {
+		fmt.Fprintf(sb, "default_pool_size = %d\n", pool.DefaultPoolSize)
+	}
+	if pool.MaxClientConn > 0 {
+		fmt.Fprintf(sb, "max_client_conn = %d\n", pool.MaxClientConn)
+	}
+	return sb.String()
+}
+
+// pgbouncerQuote quotes s for use in pgbouncer's auth file.
+func pgbouncerQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// roleNames returns the names of the roles on the server that can log in.
+func (srv *Server) roleNames(ctx context.Context) ([]string, error) {
+	rows, err := srv.conn.QueryContext(ctx, "SELECT rolname FROM pg_roles WHERE rolcanlogin;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// waitForPgBouncer waits until a connection through pgbouncer succeeds,
+// using the driver given by WithDriverName.
+func (srv *Server) waitForPgBouncer(ctx context.Context, dsn string, exited <-chan struct{}) error {
+	db, err := sql.Open(srv.cfg.driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	delay := 1 * time.Millisecond
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-time.After(delay):
+		case <-exited:
+			return fmt.Errorf("pgbouncer exited (last error: %v)", err)
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		}
+		if delay *= 2; delay > 25*time.Millisecond {
+			delay = 25 * time.Millisecond
+		}
+	}
+}
@@ -20,35 +20,77 @@
 package postgrestest
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const superuserName = "postgres"
 
 // A Server represents a running PostgreSQL server.
 type Server struct {
-	dir     string
+	dir     string // holds the Unix socket and log file
+	dataDir string
 	baseURL *url.URL
-	conn    *sql.DB
+	conn    AdminConn
+	cfg     *startConfig
+	pid     int // of the postmaster
+	port    int
+	resumed bool
 
+	// tcpPort is the port the server listens on over TCP,
+	// or zero if it only listens on its Unix socket.
+	tcpPort int
+	// password is the superuser's password for TCP connections.
+	password string
+	// logTail copies the server's log to the transcript, if any.
+	logTail *logTailer
+	// procTranscript receives pg_ctl's output for the transcript, if any.
+	procTranscript io.Writer
+
+	// exited is closed once the pg_ctl process for the current server process
+	// exits and waitErr is set.
 	exited  <-chan struct{}
 	waitErr error
+	// monitorDone is closed once the monitor goroutine
+	// for the current server process returns.
+	monitorDone chan struct{}
+
+	done     chan struct{}
+	doneErr  error
+	doneOnce sync.Once
+
+	cleanupOnce sync.Once
+
+	mu         sync.Mutex
+	stopping   bool
+	restarting bool
+	pausedPIDs []int    // stopped by Pause
+	pausing    bool     // whether Pause is listing processes
+	databases  []string // created by Start or createDatabase
+	pools      []*sql.DB
+	cleanups   []func() // run in reverse order by Cleanup
+
+	connectRevokes map[string]*connectRevokes // keyed by database name
 }
 
 // Start starts a PostgreSQL server with an empty database and waits for it to
@@ -57,189 +99,1232 @@ type Server struct {
 // Start looks for the programs "pg_ctl" and "initdb" in PATH. If these are not
 // found, then Start searches for them in /usr/lib/postgresql/*/bin, preferring
 // the highest version found.
-func Start(ctx context.Context) (_ *Server, err error) {
+func Start(ctx context.Context, opts ...StartOption) (_ *Server, err error) {
+	cfg := newStartConfig(opts)
+	if cfg.err != nil {
+		return nil, fmt.Errorf("start postgres: %w", cfg.err)
+	}
+
 	// Prepare data directory.
-	dir, err := ioutil.TempDir("", "postgrestest")
+	dir, err := cfg.newServerDir()
 	if err != nil {
-		return nil, fmt.Errorf("start postgres: %w", err)
+		return nil, fmt.Errorf("start postgres: %w", checkDiskFull(err, cfg.tempDirRoot()))
 	}
 	defer func() {
 		if err != nil {
 			os.RemoveAll(dir)
+			err = checkDiskFull(err, cfg.tempDirRoot())
 		}
 	}()
+	if err := checkVersions("initdb", "pg_ctl"); err != nil {
+		return nil, fmt.Errorf("start postgres: %w", err)
+	}
 	dataDir := filepath.Join(dir, "data")
-	err = runCommand("initdb",
+	initdbArgs := []string{
 		"--no-sync",
-		"--username="+superuserName,
-		"-D", dataDir)
+		"--username=" + superuserName,
+		"-D", dataDir,
+	}
+	initdbArgs = append(initdbArgs, cfg.initdbArgs()...)
+	err = runCommandLog(context.Background(), cfg.transcriptPhase("initdb: "), "initdb", initdbArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("start postgres: %w", err)
 	}
-	const configFormat = "" +
-		"listen_addresses = ''\n" +
-		"unix_socket_directories = '%s'\n" +
-		"fsync = off\n" +
-		"synchronous_commit = off\n" +
-		"full_page_writes = off\n"
-	err = ioutil.WriteFile(
-		filepath.Join(dataDir, "postgresql.conf"),
-		[]byte(fmt.Sprintf(configFormat, filepath.ToSlash(dir))),
-		0666)
+	if err := writeManifest(dataDir, cfg); err != nil {
+		return nil, fmt.Errorf("start postgres: %w", err)
+	}
+	srv, err := start(ctx, dir, dataDir, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("start postgres: %w", err)
 	}
+	if err := srv.runInitSQL(ctx); err != nil {
+		srv.Cleanup()
+		return nil, fmt.Errorf("start postgres: %w", err)
+	}
+	if err := srv.setup(ctx); err != nil {
+		srv.Cleanup()
+		return nil, fmt.Errorf("start postgres: %w", err)
+	}
+	return srv, nil
+}
+
+// runInitSQL runs the scripts given by WithInitSQLReader
+// against the default database.
+func (srv *Server) runInitSQL(ctx context.Context) error {
+	for i, read := range srv.cfg.initSQL {
+		script, err := read()
+		if err != nil {
+			return fmt.Errorf("read init script %d: %w", i+1, err)
+		}
+		if _, err := srv.conn.ExecContext(ctx, script); err != nil {
+			return fmt.Errorf("run init script %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Resume starts a PostgreSQL server using an existing data directory,
+// such as one preserved by WithPreserveOnFailure, copied with
+// SnapshotCluster, or kept between test runs, and waits for it to accept
+// connections. The data directory must have been initialized by Start
+// with options that have the same ConfigHash as opts, using the same
+// major version of PostgreSQL, the same WithExtensionDir directories, and
+// the same locale environment variables (LC_ALL, LC_COLLATE, LC_CTYPE, and
+// LANG). The data directory must not be in use by another server.
+//
+// Options are applied as they are by Start, except that options that
+// only affect initialization have no effect and WithDatabases skips
+// databases that already exist. The server's Unix socket and log file are
+// placed in a new temporary directory. Cleanup stops the server and
+// removes the temporary directory, but leaves the data directory in place.
+func Resume(ctx context.Context, dataDir string, opts ...StartOption) (_ *Server, err error) {
+	cfg := newStartConfig(opts)
+	if cfg.err != nil {
+		return nil, fmt.Errorf("resume postgres: %w", cfg.err)
+	}
+	dataDir, err = filepath.Abs(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("resume postgres: %w", err)
+	}
+	if err := checkManifest(dataDir, cfg); err != nil {
+		return nil, fmt.Errorf("resume postgres: %w", err)
+	}
+	if err := checkInstalledVersion(dataDir); err != nil {
+		return nil, fmt.Errorf("resume postgres: %w", err)
+	}
+	dir, err := cfg.newServerDir()
+	if err != nil {
+		return nil, fmt.Errorf("resume postgres: %w", checkDiskFull(err, cfg.tempDirRoot()))
+	}
+	srv, err := start(ctx, dir, dataDir, cfg)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("resume postgres: %w", checkDiskFull(err, cfg.tempDirRoot()))
+	}
+	srv.resumed = true
+	if err := srv.setup(ctx); err != nil {
+		srv.Cleanup()
+		return nil, fmt.Errorf("resume postgres: %w", err)
+	}
+	return srv, nil
+}
+
+// Resumed reports whether the server was started by the Resume function,
+// as opposed to Start. It is unrelated to Server.Resume,
+// which continues a paused server.
+func (srv *Server) Resumed() bool {
+	return srv.resumed
+}
+
+// setup creates the databases named by WithDatabases
+// that do not already exist and runs the warmup statements.
+func (srv *Server) setup(ctx context.Context) error {
+	for _, dbName := range srv.cfg.databases {
+		var exists bool
+		err := srv.conn.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = $1);", dbName).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("create database %q: %w", dbName, err)
+		}
+		if !exists {
+			_, err := srv.conn.ExecContext(ctx, "CREATE DATABASE "+quoteIdentifier(dbName)+";")
+			if err != nil {
+				return fmt.Errorf("create database %q: %w", dbName, err)
+			}
+		}
+		srv.databases = append(srv.databases, dbName)
+	}
+	if srv.cfg.warmup != nil {
+		if err := srv.warmup(ctx, srv.cfg.warmup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// warmupQueries read the system catalogs that planning most queries needs,
+// which loads them into the server's shared buffers.
+var warmupQueries = []string{
+	"SELECT count(*) FROM pg_class;",
+	"SELECT count(*) FROM pg_attribute;",
+	"SELECT count(*) FROM pg_type;",
+	"SELECT count(*) FROM pg_proc;",
+	"SELECT count(*) FROM pg_operator;",
+}
+
+// warmup runs warmupQueries followed by the given statements
+// against the default database.
+func (srv *Server) warmup(ctx context.Context, stmts []string) error {
+	for _, q := range warmupQueries {
+		if _, err := srv.conn.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("warmup: %w", err)
+		}
+	}
+	for _, stmt := range stmts {
+		if _, err := srv.conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("warmup: %w", err)
+		}
+	}
+	return nil
+}
+
+// start starts a server for the given data directory
+// and waits for it to accept connections.
+// dir is used for the server's Unix socket and log file.
+func start(ctx context.Context, dir, dataDir string, cfg *startConfig) (*Server, error) {
+	query := url.Values{
+		"host":    []string{dir},
+		"sslmode": []string{cfg.dsnSSLMode()},
+	}
+	srv := &Server{
+		dir:     dir,
+		dataDir: dataDir,
+		cfg:     cfg,
+		done:    make(chan struct{}),
+	}
+	if cfg.listenTLS() {
+		// The Unix socket's name includes the port,
+		// so data source names must include it too.
+		var err error
+		srv.tcpPort, err = freeTCPPort()
+		if err != nil {
+			return nil, err
+		}
+		query.Set("port", strconv.Itoa(srv.tcpPort))
+		if err := writeTLSCert(dir); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.channelBinding {
+		var err error
+		srv.password, err = randomString(24)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.dsnUsesTLS() {
+		query.Set("host", "127.0.0.1")
+		if mode := cfg.dsnSSLMode(); mode == "verify-ca" || mode == "verify-full" {
+			query.Set("sslrootcert", filepath.Join(dir, tlsCertFileName))
+		}
+	}
+	for k, v := range cfg.dsnParams {
+		query.Set(k, v)
+	}
+	srv.baseURL = &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(superuserName, srv.password),
+		RawQuery: query.Encode(),
+	}
+	if err := srv.startProcess(ctx); err != nil {
+		return nil, err
+	}
+	if srv.password != "" {
+		if err := srv.setPassword(ctx); err != nil {
+			srv.Cleanup()
+			return nil, err
+		}
+	}
+	return srv, nil
+}
+
+// setPassword sets the superuser's password to srv.password.
+// Connections over the Unix socket are trusted,
+// so the password is only needed for TCP connections.
+// The password is sent over the administrative connection
+// instead of being passed to initdb with --pwfile,
+// so it is never written to a file.
+// A standby cannot change roles, so setPassword does nothing on a standby:
+// it has the password of its primary.
+func (srv *Server) setPassword(ctx context.Context) error {
+	var inRecovery bool
+	if err := srv.conn.QueryRowContext(ctx, "SELECT pg_is_in_recovery();").Scan(&inRecovery); err != nil {
+		return err
+	}
+	if inRecovery {
+		return nil
+	}
+	_, err := srv.conn.ExecContext(ctx, "ALTER ROLE "+quoteIdentifier(superuserName)+" PASSWORD "+quoteLiteral(srv.password)+";")
+	return err
+}
+
+// startProcess starts the server process, opens srv.conn,
+// and waits for the server to accept connections.
+func (srv *Server) startProcess(ctx context.Context) (err error) {
+	dataDir := srv.dataDir
+	if err := writeConfig(dataDir, srv.dir, srv.tcpPort, srv.cfg); err != nil {
+		return err
+	}
+	if srv.cfg.listenTLS() {
+		if err := ioutil.WriteFile(filepath.Join(dataDir, "pg_hba.conf"), []byte(hbaTLS(srv.cfg.channelBinding)), 0600); err != nil {
+			return err
+		}
+	}
 
 	// Start server process.
 	// On Unix systems, pg_ctl runs as a daemon.
 	// On Windows systems, pg_ctl runs in the foreground (not well-documented) and
 	// drops privileges as needed.
-	logFile := filepath.Join(dir, "log.txt")
-	proc, err := command("pg_ctl", "start", "--no-wait", "--pgdata="+dataDir, "--log="+logFile)
+	logFile := srv.LogFile()
+	args := []string{"start", "--no-wait", "--pgdata=" + dataDir, "--log=" + logFile}
+	if srv.cfg.coreDumps {
+		args = append(args, "--core-files")
+	}
+	proc, err := command("pg_ctl", args...)
 	if err != nil {
-		return nil, fmt.Errorf("start postgres: %w", err)
+		return err
+	}
+	var procLogs []io.Writer
+	if w := srv.cfg.processLog; w != nil {
+		procLogs = append(procLogs, w)
+	}
+	if w := srv.cfg.transcriptPhase("pg_ctl: "); w != nil {
+		procLogs = append(procLogs, w)
+		srv.procTranscript = w
+	}
+	switch len(procLogs) {
+	case 0:
+	case 1:
+		proc.Stdout = procLogs[0]
+		proc.Stderr = procLogs[0]
+	default:
+		w := io.MultiWriter(procLogs...)
+		proc.Stdout = w
+		proc.Stderr = w
+	}
+	if w := srv.cfg.transcriptPhase("postgres: "); w != nil {
+		srv.logTail = tailLog(logFile, w)
 	}
 	if err := proc.Start(); err != nil {
-		return nil, fmt.Errorf("start postgres: %w", err)
+		srv.closeLogTail()
+		return err
 	}
 	exited := make(chan struct{})
-	srv := &Server{
-		dir: dir,
-		baseURL: &url.URL{
-			Scheme: "postgres",
-			Host:   "localhost",
-			User:   url.UserPassword(superuserName, ""),
-			Path:   "/",
-			RawQuery: (&url.Values{
-				"host":    []string{dir},
-				"sslmode": []string{"disable"},
-			}).Encode(),
-		},
-		exited: exited,
-	}
+	srv.exited = exited
 	go func() {
 		defer close(exited)
 		srv.waitErr = proc.Wait()
 	}()
 
 	// Wait for server to come up healthy.
-	srv.conn, err = sql.Open("postgres", srv.DefaultDatabase())
+	srv.conn, err = srv.cfg.openAdminConn(srv.adminDSN())
 	if err != nil {
 		// Failure to open means the DSN is invalid. Connections aren't created
 		// until we ping.
 		srv.stop()
-		return nil, fmt.Errorf("start postgres: %w", err)
+		return err
 	}
 	defer func() {
 		if err != nil {
 			srv.conn.Close()
 		}
 	}()
-	srv.conn.SetMaxOpenConns(1)
+	if err := pingUntilReady(ctx, srv.conn.PingContext, srv.cfg.readinessInitial, srv.cfg.readinessMax); err != nil {
+		srv.stop()
+		logOutput, _ := ioutil.ReadFile(logFile)
+		if len(logOutput) == 0 {
+			return err
+		}
+		return fmt.Errorf("%w\n%s", err, logOutput)
+	}
+	pid, port, err := readPostmasterPID(dataDir)
+	if err != nil {
+		srv.stop()
+		return err
+	}
+	srv.pid = pid
+	srv.port = port
+	srv.monitorDone = make(chan struct{})
+	go srv.monitor(pid, srv.monitorDone)
+	return nil
+}
+
+// pingUntilReady calls ping until it succeeds, waiting between attempts
+// with exponential backoff from initial up to max. If ctx is done first,
+// the returned error reports the number of attempts and the last error
+// from ping that was not caused by ctx.
+func pingUntilReady(ctx context.Context, ping func(context.Context) error, initial, max time.Duration) error {
+	attempts := 0
+	var pingErr error
+	delay := initial
 	for {
+		attempts++
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() == nil {
+			pingErr = err
+		}
+
 		select {
+		case <-time.After(delay):
 		case <-ctx.Done():
-			srv.stop()
-			logOutput, _ := ioutil.ReadFile(logFile)
-			if len(logOutput) == 0 {
-				return nil, fmt.Errorf("start postgres: %w", ctx.Err())
+			if pingErr == nil {
+				return fmt.Errorf("%w (%d connection attempts)", ctx.Err(), attempts)
+			}
+			return fmt.Errorf("%w (%d connection attempts, last error: %v)", ctx.Err(), attempts, pingErr)
+		}
+		if delay *= 2; delay > max {
+			delay = max
+		}
+	}
+}
+
+// readPostmasterPID returns the process ID and port of the server running
+// in the given data directory.
+func readPostmasterPID(dataDir string) (pid, port int, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, "postmaster.pid"))
+	if err != nil {
+		return 0, 0, err
+	}
+	// The file's first line is the process ID and its fourth line is the port.
+	// https://www.postgresql.org/docs/current/storage-file-layout.html
+	lines := strings.Split(string(data), "\n")
+	if len(lines) < 4 {
+		return 0, 0, fmt.Errorf("read postmaster.pid: too few lines")
+	}
+	pid, err = strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("read postmaster.pid: %w", err)
+	}
+	port, err = strconv.Atoi(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("read postmaster.pid: %w", err)
+	}
+	return pid, port, nil
+}
+
+// monitor waits for the server process to exit, then closes srv.done
+// unless the server is being restarted. monitor closes monitorDone
+// before returning.
+func (srv *Server) monitor(pid int, monitorDone chan<- struct{}) {
+	defer close(monitorDone)
+	err := srv.waitForExit(pid)
+	srv.mu.Lock()
+	stopping := srv.stopping
+	restarting := srv.restarting
+	srv.mu.Unlock()
+	if restarting {
+		return
+	}
+	var doneErr error
+	if !stopping {
+		if err != nil {
+			doneErr = fmt.Errorf("postgres server (pid %d) exited unexpectedly: %w", pid, err)
+		} else {
+			doneErr = fmt.Errorf("postgres server (pid %d) exited unexpectedly", pid)
+		}
+		if srv.cfg.coreDumps {
+			if cores := srv.describeCoreDumps(); cores != "" {
+				doneErr = fmt.Errorf("%w\n%s", doneErr, cores)
 			}
-			return nil, fmt.Errorf("start postgres: %w\n%s", ctx.Err(), logOutput)
-		default:
-			if err := srv.conn.PingContext(ctx); err == nil {
-				return srv, nil
+		}
+	}
+	srv.finish(doneErr)
+}
+
+// finish records err as the result of Err and closes srv.done.
+// Calls after the first do nothing.
+func (srv *Server) finish(err error) {
+	srv.doneOnce.Do(func() {
+		srv.doneErr = err
+		close(srv.done)
+	})
+}
+
+// restart stops the server, calls f, then starts the server again.
+// The server is started again even if f fails, so that a failed operation
+// does not take down a server shared by other tests.
+// If the server cannot be restarted, the server is considered done.
+func (srv *Server) restart(ctx context.Context, f func() error) error {
+	select {
+	case <-srv.done:
+		if err := srv.Err(); err != nil {
+			return err
+		}
+		return errors.New("server is stopped")
+	default:
+	}
+	srv.mu.Lock()
+	srv.restarting = true
+	srv.mu.Unlock()
+	srv.conn.Close()
+	srv.stop()
+	<-srv.monitorDone
+
+	err := f()
+	startErr := srv.startProcess(ctx)
+	srv.mu.Lock()
+	srv.stopping = false
+	srv.restarting = false
+	srv.mu.Unlock()
+	if startErr != nil {
+		srv.finish(fmt.Errorf("restart postgres: %w", startErr))
+		if err != nil {
+			return fmt.Errorf("%w (restarting server also failed: %v)", err, startErr)
+		}
+		return startErr
+	}
+	return err
+}
+
+// writeConfig writes the postgresql.conf file in dataDir.
+// The server will listen on a Unix socket in socketDir.
+func writeConfig(dataDir, socketDir string, tcpPort int, cfg *startConfig) error {
+	version, err := readDataDirVersion(dataDir)
+	if err != nil {
+		return err
+	}
+	settings, err := cfg.serverSettings(majorVersionNumber(version))
+	if err != nil {
+		return err
+	}
+	if _, set := settings["cluster_name"]; !set {
+		// Label the server's processes in ps output with the directory name,
+		// which is unique to the server.
+		settings["cluster_name"] = filepath.Base(socketDir)
+	}
+	for _, f := range cfg.configFuncs {
+		settings = f(settings)
+		if settings == nil {
+			settings = make(map[string]string)
+		}
+	}
+	mandatory := map[string]string{
+		"listen_addresses":        "",
+		"unix_socket_directories": filepath.ToSlash(socketDir),
+	}
+	if tcpPort != 0 {
+		mandatory["listen_addresses"] = "127.0.0.1"
+		mandatory["port"] = strconv.Itoa(tcpPort)
+	}
+	if cfg.listenTLS() {
+		mandatory["ssl"] = "on"
+		mandatory["ssl_cert_file"] = filepath.ToSlash(filepath.Join(socketDir, tlsCertFileName))
+		mandatory["ssl_key_file"] = filepath.ToSlash(filepath.Join(socketDir, tlsKeyFileName))
+	}
+	for k, v := range mandatory {
+		settings[k] = v
+	}
+	buf := new(bytes.Buffer)
+	if cfg.configFile != "" {
+		base, err := ioutil.ReadFile(cfg.configFile)
+		if err != nil {
+			return err
+		}
+		for _, name := range configFileParams(base) {
+			if _, overridden := mandatory[name]; overridden {
+				fmt.Fprintf(cfg.warnings(), "warning: %s sets %s, which is overridden\n", cfg.configFile, name)
 			}
 		}
+		// Settings later in the file take precedence over the included file.
+		fmt.Fprintf(buf, "include %s\n", quoteConfigString(filepath.ToSlash(cfg.configFile)))
+	}
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s = %s\n", k, quoteConfigString(settings[k]))
 	}
+	return ioutil.WriteFile(filepath.Join(dataDir, "postgresql.conf"), buf.Bytes(), 0666)
+}
+
+// configFileParams returns the lower-cased names of the parameters
+// set in the contents of a postgresql.conf file.
+// It does not follow include directives.
+func configFileParams(conf []byte) []string {
+	var names []string
+	for _, line := range strings.Split(string(conf), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		end := strings.IndexAny(line, " \t=")
+		if end == -1 {
+			end = len(line)
+		}
+		names = append(names, strings.ToLower(line[:end]))
+	}
+	return names
+}
+
+// quoteConfigString quotes s as a string value in postgresql.conf.
+func quoteConfigString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// An AdminConn is a connection to a server's default database.
+// A Server uses its AdminConn for administrative statements
+// like CREATE DATABASE. *sql.DB implements AdminConn.
+type AdminConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PingContext(ctx context.Context) error
+	Close() error
+}
+
+// DataDir returns the path of the server's data directory,
+// the directory that initdb populated.
+// For a server created by Start, it is a subdirectory of the temporary
+// directory that also holds the server's Unix socket and log file,
+// all of which Cleanup removes. For a server created by Resume,
+// it is the directory passed to Resume, which Cleanup leaves in place.
+// Tools like pg_controldata can inspect it while the server is running,
+// but the files must not be modified.
+func (srv *Server) DataDir() string {
+	return srv.dataDir
+}
+
+// LogFile returns the path of the server's log file.
+// Cleanup removes the file along with the server's other files.
+func (srv *Server) LogFile() string {
+	return filepath.Join(srv.dir, "log.txt")
 }
 
 // DefaultDatabase returns the data source name of the default "postgres" database.
 func (srv *Server) DefaultDatabase() string {
-	return srv.dsn("postgres")
+	return srv.DSN("postgres")
+}
+
+// adminDSN returns the data source name for the server's administrative
+// connection. The administrative connection always uses the Unix socket
+// without TLS, regardless of the options given.
+func (srv *Server) adminDSN() string {
+	u := *srv.baseURL
+	u.Path = "postgres"
+	query := url.Values{
+		"host":    []string{srv.dir},
+		"sslmode": []string{"disable"},
+	}
+	if srv.tcpPort != 0 {
+		query.Set("port", strconv.Itoa(srv.tcpPort))
+	}
+	u.RawQuery = query.Encode()
+	return dsnString(&u)
 }
 
+// dsnString formats u as a data source name.
+// u.Path is the unescaped database name.
 func dsnString(u *url.URL) string {
-	dsn := u.String()
-	// We need to set a non-empty Host, otherwise the / separating hostname and
-	// path will be missing from the String() representation. Hence, we replace
-	// the first 'localhost' Host with the empty string textually:
-	dsn = strings.Replace(dsn, "localhost", "", 1)
-	return dsn
+	// The database name is the whole path, even if it begins with or contains
+	// a slash, so always add the leading slash that separates it from the
+	// (empty) host. Since u.User is set, String includes the "//" authority
+	// prefix even though the host is empty.
+	u2 := *u
+	u2.Host = ""
+	u2.Path = "/" + u.Path
+	u2.RawPath = ""
+	return u2.String()
+}
+
+// DSN returns the data source name of the database with the given name,
+// which need not have been created by the server.
+// The name is escaped as needed, so it may contain any characters.
+// The data source name includes the parameters set by WithSSLMode
+// and WithDSNParams. It connects as the superuser,
+// so programs using it can create their own databases and roles.
+func (srv *Server) DSN(dbName string) string {
+	u := *srv.baseURL
+	u.Path = dbName
+	return dsnString(&u)
 }
 
-func (srv *Server) dsn(dbName string) string {
+// EnvVars returns environment variables in the form "key=value"
+// that make libpq-based programs like psql connect to the given database
+// on the server by default.
+func (srv *Server) EnvVars(dbName string) []string {
+	q := srv.baseURL.Query()
+	vars := []string{
+		"PGHOST=" + q.Get("host"),
+		"PGPORT=" + strconv.Itoa(srv.port),
+		"PGUSER=" + superuserName,
+		"PGPASSWORD=" + srv.password,
+		"PGDATABASE=" + dbName,
+		"PGSSLMODE=" + srv.cfg.dsnSSLMode(),
+	}
+	if rootCert := q.Get("sslrootcert"); rootCert != "" {
+		vars = append(vars, "PGSSLROOTCERT="+rootCert)
+	}
+	return vars
+}
+
+// DSNWithSearchPath returns the data source name of the given database
+// with its search_path set to the given schema.
+// Unqualified names in connections opened with the data source name
+// resolve to the schema.
+func (srv *Server) DSNWithSearchPath(dbName, schema string) string {
 	u := *srv.baseURL
 	u.Path = dbName
+	q := u.Query()
+	q.Set("options", "-c search_path="+escapeOptionValue(quoteIdentifier(schema)))
+	u.RawQuery = q.Encode()
 	return dsnString(&u)
 }
 
+// escapeOptionValue escapes s for use as a value in the "options"
+// connection parameter, which separates arguments with spaces.
+func escapeOptionValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, " ", `\ `)
+	return s
+}
+
+// quoteIdentifier quotes s as an SQL identifier.
+func quoteIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes s as an SQL string literal.
+// It assumes standard_conforming_strings is on, the default since PostgreSQL 9.1.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
 // NewDatabase opens a connection to a freshly created database on the server.
-func (srv *Server) NewDatabase(ctx context.Context) (*sql.DB, error) {
-	dsn, err := srv.CreateDatabase(ctx)
+// NewDatabase pings the database before returning,
+// so problems connecting to it are reported by NewDatabase
+// instead of by the first query.
+// The returned *sql.DB is closed by Cleanup if it is still open.
+// The *sql.DB does not limit the number of open connections,
+// so many parallel tests can exceed the server's max_connections;
+// use NewDatabaseLimited to avoid this.
+func (srv *Server) NewDatabase(ctx context.Context, opts ...DatabaseOption) (*sql.DB, error) {
+	db, _, err := srv.newDatabase(ctx, newDatabaseConfig(opts))
+	if err != nil {
+		return nil, fmt.Errorf("new database: %w", err)
+	}
+	return db, nil
+}
+
+// newDatabase creates a database, opens a connection pool to it,
+// and checks that the server accepts connections to it.
+// If any step fails, the database is dropped.
+func (srv *Server) newDatabase(ctx context.Context, cfg *databaseConfig) (*sql.DB, string, error) {
+	dbName, err := srv.createDatabase(ctx, cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	db, err := srv.openDB(srv.DSN(dbName))
+	if err != nil {
+		srv.dropDatabase(context.Background(), dbName)
+		return nil, "", err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		srv.dropDatabase(context.Background(), dbName)
+		return nil, "", err
+	}
+	return db, dbName, nil
+}
+
+// NewDatabaseSeeded is like NewDatabase, but runs seed in a transaction
+// on the new database before returning it. If seed returns an error
+// or the transaction fails to commit, the transaction is rolled back,
+// the database is dropped, and NewDatabaseSeeded returns the error.
+func (srv *Server) NewDatabaseSeeded(ctx context.Context, seed func(ctx context.Context, tx *sql.Tx) error, opts ...DatabaseOption) (*sql.DB, error) {
+	db, dbName, err := srv.newDatabase(ctx, newDatabaseConfig(opts))
+	if err != nil {
+		return nil, fmt.Errorf("new database: %w", err)
+	}
+	fail := func(err error) (*sql.DB, error) {
+		db.Close()
+		srv.dropDatabase(context.Background(), dbName)
+		return nil, fmt.Errorf("new database: seed: %w", err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	if err := seed(ctx, tx); err != nil {
+		tx.Rollback()
+		return fail(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return db, nil
+}
+
+// DefaultMaxOpenConns is a number of open connections per *sql.DB
+// that lets dozens of parallel tests share a server
+// with the default max_connections of 100.
+const DefaultMaxOpenConns = 4
+
+// NewDatabaseLimited is like NewDatabase, but the returned *sql.DB
+// opens at most maxOpen connections to the server at a time.
+// If maxOpen <= 0, DefaultMaxOpenConns is used.
+func (srv *Server) NewDatabaseLimited(ctx context.Context, maxOpen int) (*sql.DB, error) {
+	if maxOpen <= 0 {
+		maxOpen = DefaultMaxOpenConns
+	}
+	db, err := srv.NewDatabase(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return sql.Open("postgres", dsn)
+	db.SetMaxOpenConns(maxOpen)
+	return db, nil
+}
+
+// NewDatabaseContext is like NewDatabase, but the returned *sql.DB
+// is closed as soon as ctx is done, tying the pool's lifetime
+// to the context instead of relying on the caller to close it.
+// The *sql.DB is unusable after ctx is done: calls on it return
+// an error reporting that the database is closed.
+func (srv *Server) NewDatabaseContext(ctx context.Context, opts ...DatabaseOption) (*sql.DB, error) {
+	db, err := srv.NewDatabase(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			db.Close()
+		case <-srv.done:
+			// Cleanup closes db.
+		}
+	}()
+	return db, nil
+}
+
+// openDB opens a *sql.DB for the given data source name
+// that is closed by Cleanup.
+func (srv *Server) openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open(srv.cfg.driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	srv.mu.Lock()
+	srv.pools = append(srv.pools, db)
+	srv.mu.Unlock()
+	return db, nil
 }
 
 // CreateDatabase creates a new database on the server and returns its
 // data source name.
-func (srv *Server) CreateDatabase(ctx context.Context) (string, error) {
-	dbName, err := randomString(16)
+func (srv *Server) CreateDatabase(ctx context.Context, opts ...DatabaseOption) (string, error) {
+	dbName, err := srv.createDatabase(ctx, newDatabaseConfig(opts))
 	if err != nil {
 		return "", fmt.Errorf("new database: %w", err)
 	}
-	_, err = srv.conn.ExecContext(ctx, "CREATE DATABASE \""+dbName+"\";")
+	return srv.DSN(dbName), nil
+}
+
+// createDatabase creates a new database with a random name
+// and returns its name.
+func (srv *Server) createDatabase(ctx context.Context, cfg *databaseConfig) (string, error) {
+	dbName, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+	if err := srv.checkDatabaseConfig(ctx, cfg); err != nil {
+		return "", err
+	}
+	_, err = srv.conn.ExecContext(ctx, cfg.createStatement(dbName))
 	if err != nil {
-		return "", fmt.Errorf("new database: %w", err)
+		return "", cfg.explainCreateError(err)
+	}
+	srv.mu.Lock()
+	srv.databases = append(srv.databases, dbName)
+	srv.mu.Unlock()
+	return dbName, nil
+}
+
+// DropDatabase drops the database with the given name,
+// terminating any connections to it first.
+func (srv *Server) DropDatabase(ctx context.Context, dbName string) error {
+	if err := srv.dropDatabase(ctx, dbName); err != nil {
+		return fmt.Errorf("drop database %q: %w", dbName, err)
+	}
+	return nil
+}
+
+// DropAllDatabases drops every database created by Start, CreateDatabase,
+// or NewDatabase that has not already been dropped.
+func (srv *Server) DropAllDatabases(ctx context.Context) error {
+	srv.mu.Lock()
+	dbNames := append([]string(nil), srv.databases...)
+	srv.mu.Unlock()
+	for _, dbName := range dbNames {
+		if err := srv.DropDatabase(ctx, dbName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parameters for retrying DROP DATABASE when clients reconnect
+// between terminating backends and dropping the database.
+const (
+	dropDatabaseAttempts       = 5
+	dropDatabaseInitialBackoff = 10 * time.Millisecond
+)
+
+// dropDatabase drops the database with the given name,
+// terminating connections to it and retrying
+// while other clients are connected.
+func (srv *Server) dropDatabase(ctx context.Context, dbName string) error {
+	backoff := dropDatabaseInitialBackoff
+	for attempt := 1; ; attempt++ {
+		_, err := srv.conn.ExecContext(ctx,
+			"SELECT pg_terminate_backend(pid) FROM pg_stat_activity "+
+				"WHERE datname = $1 AND pid <> pg_backend_pid();", dbName)
+		if err != nil {
+			return err
+		}
+		_, err = srv.conn.ExecContext(ctx, "DROP DATABASE IF EXISTS "+quoteIdentifier(dbName)+";")
+		if err == nil {
+			srv.forgetDatabase(dbName)
+			return nil
+		}
+		if !isObjectInUse(err) {
+			return err
+		}
+		if attempt >= dropDatabaseAttempts {
+			if clients := srv.describeClients(ctx, dbName); clients != "" {
+				return fmt.Errorf("%w (connected after %d attempts: %s)", err, attempt, clients)
+			}
+			return fmt.Errorf("%w (after %d attempts)", err, attempt)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%w (after %d attempts)", ctx.Err(), attempt)
+		}
+		backoff *= 2
 	}
-	return srv.dsn(dbName), nil
+}
+
+// forgetDatabase removes dbName from the list of databases
+// reported by Cleanup.
+func (srv *Server) forgetDatabase(dbName string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for i, name := range srv.databases {
+		if name == dbName {
+			srv.databases = append(srv.databases[:i], srv.databases[i+1:]...)
+			return
+		}
+	}
+}
+
+// isObjectInUse reports whether err is PostgreSQL's object_in_use error,
+// which DROP DATABASE returns while other sessions are connected.
+func isObjectInUse(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "55006"
+	}
+	return strings.Contains(err.Error(), "is being accessed by other users")
+}
+
+// describeClients returns a human-readable list of the sessions
+// connected to the given database, or the empty string if there are none
+// or they cannot be listed.
+func (srv *Server) describeClients(ctx context.Context, dbName string) string {
+	sessions, err := srv.activity(ctx, dbName)
+	if err != nil {
+		return ""
+	}
+	clients := make([]string, 0, len(sessions))
+	for _, sess := range sessions {
+		clients = append(clients, sess.String())
+	}
+	return strings.Join(clients, "; ")
+}
+
+// Done returns a channel that is closed once the server process exits,
+// either because Cleanup stopped it or because it terminated unexpectedly.
+func (srv *Server) Done() <-chan struct{} {
+	return srv.done
+}
+
+// Err returns a non-nil error if the server process exited
+// without Cleanup being called, such as when the server crashes
+// or is killed by the operating system.
+// Otherwise, Err returns nil.
+func (srv *Server) Err() error {
+	select {
+	case <-srv.done:
+		return srv.doneErr
+	default:
+		return nil
+	}
+}
+
+// Query runs a query against the server's default database
+// using the server's administrative connection.
+// It is intended for inspecting server-wide state,
+// like the contents of pg_available_extensions.
+// The administrative connection is shared with methods like CreateDatabase,
+// so the caller should close the returned rows promptly.
+func (srv *Server) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return srv.conn.QueryContext(ctx, query, args...)
+}
+
+// TB is the subset of testing.TB used by the package's test helpers.
+// *testing.T and *testing.B satisfy it. The package accepts TB instead of
+// testing.TB so that programs using the package do not import
+// the testing package outside of tests.
+type TB interface {
+	Cleanup(func())
+	Errorf(format string, args ...interface{})
+	Failed() bool
+	Fatal(args ...interface{})
+	Helper()
+	Log(args ...interface{})
+}
+
+// Watch reports an error to tb if the server exits unexpectedly
+// before the end of the test, such as when the server crashes
+// or is killed by the operating system.
+func (srv *Server) Watch(tb TB) {
+	stop := make(chan struct{})
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		select {
+		case <-srv.Done():
+			if err := srv.Err(); err != nil {
+				tb.Errorf("postgrestest: %v", err)
+			}
+		case <-stop:
+		}
+	}()
+	tb.Cleanup(func() {
+		close(stop)
+		<-watchDone
+	})
 }
 
 // Cleanup shuts down the server and deletes any on-disk files the server used.
+// Cleanup first closes any *sql.DB returned by the server's methods,
+// then closes the server's administrative connection,
+// then stops the server, and finally removes the server's files.
+// Databases are not dropped individually, since removing the server's files
+// removes them, except for those of tenants created by NewTenant,
+// which are dropped along with their roles.
+//
+// If the server was started with WithPreserveOnFailure and the test failed,
+// Cleanup leaves the server running and logs how to connect to it instead.
+// Calls to Cleanup after the first do nothing.
 func (srv *Server) Cleanup() {
+	srv.cleanupOnce.Do(srv.cleanup)
+}
+
+func (srv *Server) cleanup() {
+	// A paused server cannot handle closed connections or shutdown.
+	srv.Resume()
+	tb := srv.cfg.preserveOnFailure
+	preserve := tb != nil && tb.Failed()
+	srv.mu.Lock()
+	pools := srv.pools
+	srv.pools = nil
+	cleanups := srv.cleanups
+	srv.cleanups = nil
+	srv.mu.Unlock()
+	if !preserve {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}
+	for _, db := range pools {
+		db.Close()
+	}
 	if srv.conn != nil {
 		srv.conn.Close()
 	}
+	if preserve {
+		srv.closeLogTail()
+		srv.logPreserved(tb)
+		return
+	}
 	srv.stop()
 	os.RemoveAll(srv.dir)
 }
 
+// addCleanup registers a function for Cleanup to call
+// while the server is still running.
+func (srv *Server) addCleanup(f func()) {
+	srv.mu.Lock()
+	srv.cleanups = append(srv.cleanups, f)
+	srv.mu.Unlock()
+}
+
+// logPreserved logs the location of a server preserved by WithPreserveOnFailure.
+func (srv *Server) logPreserved(tb TB) {
+	dataDir := srv.dataDir
+	msg := new(strings.Builder)
+	fmt.Fprintf(msg, "postgrestest: test failed; leaving server running in %s\n", srv.dir)
+	fmt.Fprintf(msg, "Default database: %s\n", srv.DefaultDatabase())
+	srv.mu.Lock()
+	for _, dbName := range srv.databases {
+		fmt.Fprintf(msg, "Created database: %s\n", srv.DSN(dbName))
+	}
+	srv.mu.Unlock()
+	fmt.Fprintf(msg, "To clean up, run: pg_ctl stop --pgdata=%s && rm -rf %s", dataDir, srv.dir)
+	tb.Log(msg)
+}
+
 func (srv *Server) stop() {
+	srv.mu.Lock()
+	srv.stopping = true
+	srv.mu.Unlock()
+
 	// Use Immediate Shutdown mode. We don't care about data corruption.
 	// https://www.postgresql.org/docs/current/server-shutdown.html
 	//
 	// TODO(someday): What happens if this fails?
 	runCommand("pg_ctl", "stop",
-		"--pgdata="+filepath.Join(srv.dir, "data"),
+		"--pgdata="+srv.dataDir,
 		"--mode=immediate",
 		"--wait")
 	<-srv.exited
+	srv.closeLogTail()
+}
+
+// closeLogTail stops copying the server's log to the transcript, if any,
+// and writes any partial lines from the server or pg_ctl to the transcript.
+func (srv *Server) closeLogTail() {
+	if srv.logTail != nil {
+		srv.logTail.close()
+		srv.logTail = nil
+	}
+	if srv.procTranscript != nil {
+		flushTranscript(srv.procTranscript)
+		srv.procTranscript = nil
+	}
 }
 
 // command creates an *exec.Cmd for the given PostgreSQL program. If it it
 // cannot find the program on the PATH, then it searches some well-known
 // PostgreSQL installation paths.
 func command(name string, args ...string) (*exec.Cmd, error) {
+	return commandContext(context.Background(), name, args...)
+}
+
+// commandContext is like command, but the program is killed if ctx is done
+// before the program exits.
+func commandContext(ctx context.Context, name string, args ...string) (*exec.Cmd, error) {
+	p, err := lookProgram(name)
+	if err != nil {
+		return nil, err
+	}
+	return exec.CommandContext(ctx, p, args...), nil
+}
+
+// lookProgram returns the path of the given PostgreSQL program
+// using the same search as command.
+func lookProgram(name string) (string, error) {
 	if runtime.GOOS == "windows" {
 		name += ".exe"
 	}
 	p, lookErr := exec.LookPath(name)
 	if lookErr == nil {
-		return exec.Command(p, args...), nil
+		return p, nil
 	}
 	// Find PostgreSQL installation path. If this doesn't work, return the
 	// original LookPath error, since the runner of the test should add the binary
 	// to their PATH if it can't be found.
 	postgresBin.init.Do(findPostgresBin)
 	if postgresBin.dir == "" {
-		return nil, lookErr
+		return "", lookErr
 	}
 	p = filepath.Join(postgresBin.dir, name)
 	if _, err := os.Stat(p); err != nil {
-		return nil, lookErr
+		return "", lookErr
+	}
+	return p, nil
+}
+
+// checkVersions verifies that the given PostgreSQL programs
+// are from the same major version of PostgreSQL.
+// Programs in the same directory are assumed to be from the same installation.
+func checkVersions(names ...string) error {
+	paths := make([]string, len(names))
+	sameDir := true
+	for i, name := range names {
+		p, err := lookProgram(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		paths[i] = p
+		sameDir = sameDir && filepath.Dir(p) == filepath.Dir(paths[0])
+	}
+	if sameDir {
+		return nil
+	}
+	versions := make([]string, len(names))
+	for i, p := range paths {
+		var err error
+		versions[i], err = programPathMajorVersion(p)
+		if err != nil {
+			return err
+		}
+		if versions[i] != versions[0] {
+			return fmt.Errorf("%s is from PostgreSQL %s, but %s is from PostgreSQL %s; "+
+				"add the bin directory of a single PostgreSQL installation to PATH",
+				p, versions[i], paths[0], versions[0])
+		}
+	}
+	return nil
+}
+
+// programMajorVersion returns the major version of PostgreSQL
+// that the given PostgreSQL program is from, like "16" or "9.6".
+func programMajorVersion(name string) (string, error) {
+	p, err := lookProgram(name)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return programPathMajorVersion(p)
+}
+
+func programPathMajorVersion(path string) (string, error) {
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s --version: %w", path, err)
+	}
+	v, err := parseMajorVersion(string(out))
+	if err != nil {
+		return "", fmt.Errorf("%s --version: %w", path, err)
+	}
+	return v, nil
+}
+
+// parseMajorVersion returns the major version from the output of
+// a PostgreSQL program's --version flag, like "initdb (PostgreSQL) 16.2".
+func parseMajorVersion(versionOutput string) (string, error) {
+	fields := strings.Fields(versionOutput)
+	if len(fields) == 0 {
+		return "", errors.New("empty version")
+	}
+	// Distributions may append their own version, as in
+	// "initdb (PostgreSQL) 14.10 (Ubuntu 14.10-0ubuntu0.22.04.1)".
+	v := fields[len(fields)-1]
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "(PostgreSQL)" {
+			v = fields[i+1]
+			break
+		}
+	}
+	// Trim suffixes like "devel" or "beta1".
+	end := 0
+	for end < len(v) && (v[end] == '.' || '0' <= v[end] && v[end] <= '9') {
+		end++
+	}
+	parts := strings.Split(strings.TrimSuffix(v[:end], "."), ".")
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("parse version %q: %w", v, err)
 	}
-	return exec.Command(p, args...), nil
+	if major >= 10 || len(parts) < 2 {
+		return strconv.Itoa(major), nil
+	}
+	// Before PostgreSQL 10, the major version had two components.
+	return parts[0] + "." + parts[1], nil
 }
 
 func findPostgresBin() {
@@ -273,11 +1358,30 @@ var postgresBin struct {
 }
 
 func runCommand(name string, args ...string) error {
-	c, err := command(name, args...)
+	return runCommandContext(context.Background(), name, args...)
+}
+
+func runCommandContext(ctx context.Context, name string, args ...string) error {
+	return runCommandLog(ctx, nil, name, args...)
+}
+
+// runCommandLog runs the given PostgreSQL program
+// and writes its combined output to log if log is not nil.
+func runCommandLog(ctx context.Context, log io.Writer, name string, args ...string) error {
+	c, err := commandContext(ctx, name, args...)
 	if err != nil {
 		return fmt.Errorf("%s: %w", name, err)
 	}
 	out, err := c.CombinedOutput()
+	if log != nil && len(out) > 0 {
+		log.Write(out)
+		if out[len(out)-1] != '\n' {
+			log.Write([]byte{'\n'})
+		}
+	}
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%s: %w", name, ctx.Err())
+	}
 	if errors.As(err, new(*exec.ExitError)) {
 		return fmt.Errorf("%s: %s", name, out)
 	}
@@ -287,6 +1391,20 @@ func runCommand(name string, args ...string) error {
 	return nil
 }
 
+// checkDiskFull adds advice to err if it was caused by the file system
+// of parent, the directory where temporary directories are created,
+// running out of space.
+func checkDiskFull(err error, parent string) error {
+	if !errors.Is(err, syscall.ENOSPC) && !strings.Contains(err.Error(), "No space left on device") {
+		return err
+	}
+	return fmt.Errorf("%w\n"+
+		"postgrestest: the file system for %s is full. "+
+		"Remove stale postgrestest* directories from it "+
+		"or use WithTempDir or TMPDIR to choose a directory on a file system with more space.",
+		err, parent)
+}
+
 func randomString(n int) (string, error) {
 	enc := base64.RawURLEncoding
 	bits := make([]byte, enc.DecodedLen(n))
@@ -20,16 +20,26 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net"
+	"net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
 
 const singleTestTime = 30 * time.Second
 
+var (
+	_ TB = (*testing.T)(nil)
+	_ TB = (*testing.B)(nil)
+)
+
 func TestStart(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
 	defer cancel()
@@ -85,6 +95,505 @@ func TestNewDatabase(t *testing.T) {
 	}
 }
 
+func TestNewDatabasePings(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	// New sessions fail to start, but the administrative connection
+	// is already open.
+	if _, err := srv.conn.ExecContext(ctx, `ALTER ROLE postgres SET session_preload_libraries = 'postgrestest_nonexistent';`); err != nil {
+		t.Fatal(err)
+	}
+	db, err := srv.NewDatabase(ctx)
+	if err == nil {
+		db.Close()
+		t.Fatal("NewDatabase did not return an error for a database that cannot be connected to")
+	}
+	t.Log(err)
+}
+
+func TestNewDatabaseSeeded(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+
+	db, err := srv.NewDatabaseSeeded(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `CREATE TABLE foo (id INTEGER PRIMARY KEY); INSERT INTO foo VALUES (1), (2);`)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM foo;`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("foo has %d rows; want 2", n)
+	}
+
+	var before int
+	if err := srv.conn.QueryRowContext(ctx, `SELECT count(*) FROM pg_database;`).Scan(&before); err != nil {
+		t.Fatal(err)
+	}
+	errSeed := errors.New("bork")
+	_, err = srv.NewDatabaseSeeded(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		return errSeed
+	})
+	if !errors.Is(err, errSeed) {
+		t.Errorf("NewDatabaseSeeded with failing seed = %v; want %v", err, errSeed)
+	}
+	var after int
+	if err := srv.conn.QueryRowContext(ctx, `SELECT count(*) FROM pg_database;`).Scan(&after); err != nil {
+		t.Fatal(err)
+	}
+	if after != before {
+		t.Errorf("Database count went from %d to %d after failed seed; want no change", before, after)
+	}
+}
+
+func TestNewDatabaseLimited(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := srv.NewDatabaseLimited(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got := db.Stats().MaxOpenConnections; got != DefaultMaxOpenConns {
+		t.Errorf("MaxOpenConnections = %d; want %d", got, DefaultMaxOpenConns)
+	}
+}
+
+func TestNewDatabaseContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dbCtx, cancelDB := context.WithCancel(ctx)
+	db, err := srv.NewDatabaseContext(dbCtx)
+	if err != nil {
+		cancelDB()
+		t.Fatal(err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancelDB()
+	for {
+		if err := db.PingContext(ctx); err != nil {
+			if !strings.Contains(err.Error(), "database is closed") {
+				t.Errorf("Ping after cancel = %v; want database is closed", err)
+			}
+			break
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("*sql.DB not closed after context was canceled")
+		}
+	}
+}
+
+func TestDataDir(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	if _, err := os.Stat(filepath.Join(srv.DataDir(), "PG_VERSION")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDropDatabase(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithDatabases("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := sql.Open("postgres", srv.DSN("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Hold a connection open so that the drop has to terminate it.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := srv.DropAllDatabases(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	err = srv.conn.QueryRowContext(ctx, "SELECT count(*) FROM pg_database WHERE datname = 'app';").Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("database \"app\" still exists after DropAllDatabases")
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	psql, err := command("psql", "--no-psqlrc", "--tuples-only", "--no-align", "--command=SELECT current_database();")
+	if err != nil {
+		t.Skip("psql not found:", err)
+	}
+	psql.Env = append(os.Environ(), srv.EnvVars("postgres")...)
+	out, err := psql.Output()
+	if err != nil {
+		t.Fatal("psql:", err)
+	}
+	if got, want := strings.TrimSpace(string(out)), "postgres"; got != want {
+		t.Errorf("psql output = %q; want %q", got, want)
+	}
+}
+
+func TestDSN(t *testing.T) {
+	t.Run("Escaping", func(t *testing.T) {
+		srv := &Server{
+			baseURL: &url.URL{
+				Scheme:   "postgres",
+				User:     url.UserPassword(superuserName, ""),
+				RawQuery: "host=%2Ftmp%2Fpostgrestest&sslmode=disable",
+			},
+		}
+		for _, dbName := range []string{"postgres", "weird name", "a/b", "/lead", "100%?#", "localhost"} {
+			dsn := srv.DSN(dbName)
+			u := mustParseURL(t, dsn)
+			if got := strings.TrimPrefix(u.Path, "/"); got != dbName {
+				t.Errorf("DSN(%q) = %q; database name parses as %q", dbName, dsn, got)
+			}
+			if got, want := u.Query().Get("host"), "/tmp/postgrestest"; got != want {
+				t.Errorf("DSN(%q) = %q; host = %q, want %q", dbName, dsn, got, want)
+			}
+		}
+	})
+	t.Run("Connect", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+		defer cancel()
+		const dbName = "weird name?"
+		srv, err := Start(ctx, WithDatabases(dbName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(srv.Cleanup)
+		db, err := sql.Open("postgres", srv.DSN(dbName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		var got string
+		if err := db.QueryRowContext(ctx, `SELECT current_database();`).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != dbName {
+			t.Errorf("current_database() = %q; want %q", got, dbName)
+		}
+	})
+}
+
+func TestDSNCanCreateDatabase(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `CREATE DATABASE tenant2;`); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDSNWithSearchPath(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	const schema = "My Schema"
+	if _, err := db.ExecContext(ctx, `CREATE SCHEMA "My Schema";`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE TABLE "My Schema".foo (id SERIAL PRIMARY KEY);`); err != nil {
+		t.Fatal(err)
+	}
+	dbName := strings.TrimPrefix(mustParseURL(t, dsn).Path, "/")
+
+	scopedDB, err := sql.Open("postgres", srv.DSNWithSearchPath(dbName, schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scopedDB.Close()
+	var n int
+	if err := scopedDB.QueryRowContext(ctx, `SELECT count(*) FROM foo;`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	rows, err := srv.Query(ctx, `SELECT datname FROM pg_database WHERE datname = $1;`, "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "postgres" {
+		t.Errorf("names = %q; want [\"postgres\"]", names)
+	}
+}
+
+func TestDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-srv.Done():
+		t.Error("Done() closed before Cleanup")
+	default:
+	}
+	srv.Cleanup()
+	select {
+	case <-srv.Done():
+	case <-ctx.Done():
+		t.Fatal("Done() not closed after Cleanup")
+	}
+	if err := srv.Err(); err != nil {
+		t.Errorf("Err() = %v after Cleanup; want <nil>", err)
+	}
+}
+
+func TestCleanupTwice(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Cleanup()
+	srv.Cleanup()
+	if _, err := os.Stat(srv.dir); !os.IsNotExist(err) {
+		t.Errorf("os.Stat(%q) = _, %v after Cleanup; want not exist", srv.dir, err)
+	}
+}
+
+func TestCleanupClosesDatabases(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := srv.NewDatabase(ctx)
+	if err != nil {
+		srv.Cleanup()
+		t.Fatal(err)
+	}
+	// Hold a connection open while the server shuts down.
+	if err := db.PingContext(ctx); err != nil {
+		t.Error(err)
+	}
+	srv.Cleanup()
+	if err := db.PingContext(ctx); err == nil {
+		t.Error("db.PingContext succeeded after Cleanup")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	// Watch should not report an error when the server is stopped by Cleanup,
+	// regardless of the order the cleanup functions run in.
+	t.Run("WatchFirst", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+		defer cancel()
+		srv, err := Start(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.Watch(t)
+		t.Cleanup(srv.Cleanup)
+	})
+	t.Run("CleanupFirst", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+		defer cancel()
+		srv, err := Start(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(srv.Cleanup)
+		srv.Watch(t)
+	})
+}
+
+func TestPingUntilReady(t *testing.T) {
+	t.Run("Timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		refused := errors.New("connection refused")
+		ping := func(ctx context.Context) error { return refused }
+		err := pingUntilReady(ctx, ping, time.Millisecond, 5*time.Millisecond)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("pingUntilReady(...) = %v; want to wrap %v", err, context.DeadlineExceeded)
+		}
+		if err == nil || !strings.Contains(err.Error(), "connection attempts, last error: connection refused") {
+			t.Errorf("pingUntilReady(...) = %v; want to report attempts and last error", err)
+		}
+	})
+	t.Run("NoAttemptErrors", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		ping := func(ctx context.Context) error { return ctx.Err() }
+		err := pingUntilReady(ctx, ping, time.Millisecond, 5*time.Millisecond)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("pingUntilReady(...) = %v; want to wrap %v", err, context.Canceled)
+		}
+		if err == nil || !strings.Contains(err.Error(), "(1 connection attempts)") {
+			t.Errorf("pingUntilReady(...) = %v; want to report 1 attempt", err)
+		}
+	})
+	t.Run("Ready", func(t *testing.T) {
+		attempts := 0
+		ping := func(ctx context.Context) error {
+			if attempts++; attempts < 3 {
+				return errors.New("starting up")
+			}
+			return nil
+		}
+		if err := pingUntilReady(context.Background(), ping, time.Millisecond, 5*time.Millisecond); err != nil {
+			t.Error("pingUntilReady:", err)
+		}
+		if attempts != 3 {
+			t.Errorf("ping called %d times; want 3", attempts)
+		}
+	})
+}
+
+func TestCheckDiskFull(t *testing.T) {
+	t.Run("ENOSPC", func(t *testing.T) {
+		orig := &os.PathError{Op: "mkdir", Path: "/scratch/postgrestest123", Err: syscall.ENOSPC}
+		err := checkDiskFull(orig, "/scratch")
+		if !errors.Is(err, syscall.ENOSPC) {
+			t.Errorf("checkDiskFull(%v) = %v; want to wrap ENOSPC", orig, err)
+		}
+		if !strings.Contains(err.Error(), "TMPDIR") {
+			t.Errorf("checkDiskFull(%v) = %v; want to mention TMPDIR", orig, err)
+		}
+		if !strings.Contains(err.Error(), "file system for /scratch is full") {
+			t.Errorf("checkDiskFull(%v) = %v; want to mention /scratch", orig, err)
+		}
+	})
+	t.Run("InitdbOutput", func(t *testing.T) {
+		orig := errors.New("initdb: could not write to file: No space left on device")
+		if err := checkDiskFull(orig, os.TempDir()); err == orig {
+			t.Errorf("checkDiskFull(%v) returned original error", orig)
+		}
+	})
+	t.Run("Unrelated", func(t *testing.T) {
+		orig := errors.New("bork")
+		if err := checkDiskFull(orig, os.TempDir()); err != orig {
+			t.Errorf("checkDiskFull(%v) = %v; want original error", orig, err)
+		}
+	})
+}
+
+func TestParseMajorVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"initdb (PostgreSQL) 16.2\n", "16"},
+		{"pg_ctl (PostgreSQL) 10.23", "10"},
+		{"pg_ctl (PostgreSQL) 9.6.24\n", "9.6"},
+		{"initdb (PostgreSQL) 17beta1\n", "17"},
+		{"initdb (PostgreSQL) 18devel\n", "18"},
+		{"initdb (PostgreSQL) 14.10 (Ubuntu 14.10-0ubuntu0.22.04.1)\n", "14"},
+		{"bork", ""},
+	}
+	for _, test := range tests {
+		got, err := parseMajorVersion(test.output)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("parseMajorVersion(%q) = %q, <nil>; want error", test.output, got)
+			}
+			continue
+		}
+		if got != test.want || err != nil {
+			t.Errorf("parseMajorVersion(%q) = %q, %v; want %q, <nil>", test.output, got, err, test.want)
+		}
+	}
+}
+
 func BenchmarkStart(b *testing.B) {
 	ctx := context.Background()
 	for i := 0; i < b.N; i++ {
@@ -96,6 +605,17 @@ func BenchmarkStart(b *testing.B) {
 	}
 }
 
+func BenchmarkStartFastStartup(b *testing.B) {
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		srv, err := Start(ctx, WithFastStartup())
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(srv.Cleanup)
+	}
+}
+
 func BenchmarkCreateDatabase(b *testing.B) {
 	ctx := context.Background()
 	srv, err := Start(ctx)
@@ -161,6 +681,15 @@ func BenchmarkDocker(b *testing.B) {
 	})
 }
 
+func mustParseURL(tb testing.TB, s string) *url.URL {
+	tb.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return u
+}
+
 type logger interface {
 	Log(...interface{})
 }
@@ -221,3 +750,15 @@ func startDocker(l logger, dockerExe string) (db *sql.DB, cleanup func(), _ erro
 		}
 	}
 }
+
+func TestMeasureStartup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	d, err := MeasureStartup(ctx, 2, WithFastStartup())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d <= 0 {
+		t.Errorf("MeasureStartup(ctx, 2) = %v; want > 0", d)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+// +build !windows
+
+package postgrestest
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// waitForExit waits for the server process to exit.
+// On Unix systems, pg_ctl exits as soon as it has started the server
+// in the background, so waitForExit polls the postmaster's process ID.
+// The postmaster is not a child of this process,
+// so its exit status is not available.
+func (srv *Server) waitForExit(pid int) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+			return nil
+		}
+	}
+	panic("unreachable")
+}
+
+// Signals sent by Pause and Resume.
+const (
+	sigStop = syscall.SIGSTOP
+	sigCont = syscall.SIGCONT
+)
+
+// signalProcesses sends sig to each of the given processes,
+// ignoring processes that have already exited.
+func signalProcesses(pids []int, sig syscall.Signal) error {
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+// +build !windows
+
+package postgrestest
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestErrAfterCrash(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	pid, _, err := readPostmasterPID(srv.dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-srv.Done():
+	case <-ctx.Done():
+		t.Fatal("Done() not closed after killing server")
+	}
+	if err := srv.Err(); err == nil {
+		t.Error("Err() = <nil> after killing server")
+	}
+}
+
+func TestWithCoreDumps(t *testing.T) {
+	pattern, err := ioutil.ReadFile("/proc/sys/kernel/core_pattern")
+	if err != nil {
+		t.Skip("Cannot determine core pattern:", err)
+	}
+	if p := strings.TrimSpace(string(pattern)); strings.HasPrefix(p, "|") || strings.Contains(p, "/") {
+		t.Skipf("kernel.core_pattern = %q does not write core files to the working directory", p)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithCoreDumps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := srv.NewDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backendPID int
+	if err := db.QueryRowContext(ctx, "SELECT pg_backend_pid();").Scan(&backendPID); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(backendPID, syscall.SIGABRT); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		cores, err := srv.CoreDumps()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cores) > 0 {
+			break
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("No core file written after crashing backend")
+		}
+	}
+}
+
+func TestPause(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := srv.NewDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	// Pausing a paused server does nothing, rather than querying it.
+	if err := srv.Pause(); err != nil {
+		srv.Resume()
+		t.Fatal("second Pause:", err)
+	}
+	queryDone := make(chan error, 1)
+	go func() {
+		var n int
+		queryDone <- db.QueryRow(`SELECT 1;`).Scan(&n)
+	}()
+	select {
+	case err := <-queryDone:
+		srv.Resume()
+		t.Fatalf("Query finished while server paused (err = %v)", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if err := srv.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-queryDone:
+		if err != nil {
+			t.Error("Query after resume:", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Query did not finish after resume")
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"errors"
+	"syscall"
+)
+
+// waitForExit waits for the server process to exit.
+// On Windows systems, pg_ctl runs in the foreground,
+// so its exit is the server's exit.
+func (srv *Server) waitForExit(pid int) error {
+	<-srv.exited
+	return srv.waitErr
+}
+
+// Signals sent by Pause and Resume.
+// Windows has no equivalent, so signalProcesses always fails.
+const (
+	sigStop syscall.Signal = 0
+	sigCont syscall.Signal = 0
+)
+
+// signalProcesses reports that pausing processes is not supported on Windows.
+func signalProcesses(pids []int, sig syscall.Signal) error {
+	return errors.New("pausing the server is not supported on Windows")
+}
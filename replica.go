@@ -0,0 +1,133 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewReplica starts a streaming replica of srv and waits for it to accept
+// connections. The replica is a read-only hot standby that is initialized
+// from a base backup of srv and uses the same options as srv.
+// srv must have been started with WithReplication.
+//
+// The caller is responsible for calling Cleanup on the returned Server
+// before calling Cleanup on srv.
+func (srv *Server) NewReplica(ctx context.Context) (_ *Server, err error) {
+	if !srv.cfg.replication {
+		return nil, errors.New("new replica: primary was not started with WithReplication")
+	}
+	dir, err := srv.cfg.newServerDir()
+	if err != nil {
+		return nil, fmt.Errorf("new replica: %w", checkDiskFull(err, srv.cfg.tempDirRoot()))
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+			err = checkDiskFull(err, srv.cfg.tempDirRoot())
+		}
+	}()
+	// --write-recovery-conf sets up the new data directory to start in standby
+	// mode, connecting to the primary with the same parameters pg_basebackup
+	// used. WAL generated during the backup is streamed over a temporary
+	// replication slot.
+	err = runCommand("pg_basebackup",
+		"--pgdata="+filepath.Join(dir, "data"),
+		"--host="+srv.dir,
+		"--port="+strconv.Itoa(srv.port),
+		"--username="+superuserName,
+		"--wal-method=stream",
+		"--write-recovery-conf",
+		"--no-sync")
+	if err != nil {
+		return nil, fmt.Errorf("new replica: %w", err)
+	}
+	replica, err := start(ctx, dir, filepath.Join(dir, "data"), srv.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("new replica: %w", err)
+	}
+	if replica.password != "" {
+		// Roles are replicated from the primary.
+		replica.password = srv.password
+		replica.baseURL.User = url.UserPassword(superuserName, srv.password)
+	}
+	return replica, nil
+}
+
+// WaitForCatchup waits until replica has replayed all the WAL
+// that primary had written at the time of the call.
+func (replica *Server) WaitForCatchup(ctx context.Context, primary *Server) error {
+	var targetString string
+	err := primary.conn.QueryRowContext(ctx, "SELECT pg_current_wal_lsn();").Scan(&targetString)
+	if err != nil {
+		return fmt.Errorf("wait for catchup: %w", err)
+	}
+	target, err := parseLSN(targetString)
+	if err != nil {
+		return fmt.Errorf("wait for catchup: primary: %w", err)
+	}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		var replayString sql.NullString
+		err := replica.conn.QueryRowContext(ctx, "SELECT pg_last_wal_replay_lsn();").Scan(&replayString)
+		if err != nil {
+			return fmt.Errorf("wait for catchup: %w", err)
+		}
+		if !replayString.Valid {
+			return errors.New("wait for catchup: server is not a replica")
+		}
+		replay, err := parseLSN(replayString.String)
+		if err != nil {
+			return fmt.Errorf("wait for catchup: replica: %w", err)
+		}
+		if replay >= target {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("wait for catchup: replayed up to %s, want %s: %w", replayString.String, targetString, ctx.Err())
+		}
+	}
+}
+
+// parseLSN parses a textual pg_lsn value like "16/B374D848".
+func parseLSN(s string) (uint64, error) {
+	i := strings.IndexByte(s, '/')
+	if i == -1 {
+		return 0, fmt.Errorf("parse lsn %q: missing '/'", s)
+	}
+	hi, err := strconv.ParseUint(s[:i], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("parse lsn %q: %w", s, err)
+	}
+	lo, err := strconv.ParseUint(s[i+1:], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("parse lsn %q: %w", s, err)
+	}
+	return hi<<32 | lo, nil
+}
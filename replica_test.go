@@ -0,0 +1,134 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestNewReplica(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	primary, err := Start(ctx, WithReplication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(primary.Cleanup)
+	replica, err := primary.NewReplica(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(replica.Cleanup)
+
+	db, err := sql.Open("postgres", replica.DefaultDatabase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var inRecovery bool
+	if err := db.QueryRowContext(ctx, "SELECT pg_is_in_recovery();").Scan(&inRecovery); err != nil {
+		t.Fatal(err)
+	}
+	if !inRecovery {
+		t.Error("pg_is_in_recovery() = false; want true")
+	}
+}
+
+func TestNewReplicaRequiresReplication(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	replica, err := srv.NewReplica(ctx)
+	if err == nil {
+		replica.Cleanup()
+		t.Fatal("NewReplica did not return an error")
+	}
+}
+
+func TestWaitForCatchup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	primary, err := Start(ctx, WithReplication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(primary.Cleanup)
+	replica, err := primary.NewReplica(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(replica.Cleanup)
+
+	primaryDB, err := sql.Open("postgres", primary.DefaultDatabase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primaryDB.Close()
+	if _, err := primaryDB.ExecContext(ctx, `CREATE TABLE foo (id SERIAL PRIMARY KEY);`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := primaryDB.ExecContext(ctx, `INSERT INTO foo DEFAULT VALUES;`); err != nil {
+		t.Fatal(err)
+	}
+	if err := replica.WaitForCatchup(ctx, primary); err != nil {
+		t.Fatal(err)
+	}
+
+	replicaDB, err := sql.Open("postgres", replica.DefaultDatabase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replicaDB.Close()
+	var n int
+	if err := replicaDB.QueryRowContext(ctx, `SELECT count(*) FROM foo;`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("replica has %d rows in foo; want 1", n)
+	}
+}
+
+func TestParseLSN(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    uint64
+		wantErr bool
+	}{
+		{s: "0/0", want: 0},
+		{s: "16/B374D848", want: 0x16_B374D848},
+		{s: "FFFFFFFF/FFFFFFFF", want: 0xFFFFFFFF_FFFFFFFF},
+		{s: "", wantErr: true},
+		{s: "B374D848", wantErr: true},
+		{s: "16/XYZ", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseLSN(test.s)
+		if got != test.want || (err != nil) != test.wantErr {
+			errString := "<nil>"
+			if test.wantErr {
+				errString = "<error>"
+			}
+			t.Errorf("parseLSN(%q) = %#x, %v; want %#x, %s", test.s, got, err, test.want, errString)
+		}
+	}
+}
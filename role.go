@@ -0,0 +1,247 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// CreateRole creates a new role on the server that is permitted to log in.
+// The role has no password: the server trusts all local connections.
+// If creating the role or applying an option fails,
+// the role is dropped and CreateRole returns an error.
+func (srv *Server) CreateRole(ctx context.Context, name string, opts ...RoleOption) error {
+	_, err := srv.conn.ExecContext(ctx, "CREATE ROLE "+quoteIdentifier(name)+" LOGIN;")
+	if err != nil {
+		return fmt.Errorf("create role %q: %w", name, err)
+	}
+	cfg := new(roleConfig)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	for _, stmt := range cfg.statements(name) {
+		if _, err := srv.conn.ExecContext(ctx, stmt); err != nil {
+			srv.conn.ExecContext(context.Background(), "DROP ROLE "+quoteIdentifier(name)+";")
+			return fmt.Errorf("create role %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// A RoleOption customizes a role created by Server.CreateRole.
+type RoleOption func(*roleConfig)
+
+type roleConfig struct {
+	settings []roleSetting
+}
+
+type roleSetting struct {
+	name   string
+	values []string
+}
+
+// statements returns the statements that apply the options
+// to the role with the given name.
+func (cfg *roleConfig) statements(role string) []string {
+	stmts := make([]string, 0, len(cfg.settings))
+	for _, setting := range cfg.settings {
+		quoted := make([]string, len(setting.values))
+		for i, v := range setting.values {
+			quoted[i] = quoteLiteral(v)
+		}
+		stmts = append(stmts, "ALTER ROLE "+quoteIdentifier(role)+
+			" SET "+quoteIdentifier(setting.name)+" TO "+strings.Join(quoted, ", ")+";")
+	}
+	return stmts
+}
+
+// RoleSetting sets the default value of a run-time parameter
+// for every session of the role, in any database (ALTER ROLE ... SET),
+// to model a production role's configuration. Parameters that take a list,
+// like search_path, are given one value per element:
+//
+//	RoleSetting("search_path", "app", "public")
+//	RoleSetting("statement_timeout", "5s")
+func RoleSetting(name string, values ...string) RoleOption {
+	return func(cfg *roleConfig) {
+		cfg.settings = append(cfg.settings, roleSetting{name: name, values: values})
+	}
+}
+
+// NewTenant creates a new role and a new database owned by that role,
+// both with the same random name. It returns a data source name that
+// connects to the database as the role. The role is not a superuser,
+// so privilege errors surface as they would in production.
+// DropTenant drops the database and the role.
+//
+// Like a database created by CreateDatabase, the tenant's database is
+// dropped by DropAllDatabases. Cleanup drops the tenant's database and role
+// before stopping the server, so the role does not outlive the server
+// in a data directory that is kept, like one used by Resume.
+func (srv *Server) NewTenant(ctx context.Context) (dsn string, role string, err error) {
+	name, err := randomString(16)
+	if err != nil {
+		return "", "", fmt.Errorf("new tenant: %w", err)
+	}
+	if err := srv.CreateRole(ctx, name); err != nil {
+		return "", "", fmt.Errorf("new tenant: %w", err)
+	}
+	_, err = srv.conn.ExecContext(ctx, "CREATE DATABASE "+quoteIdentifier(name)+" OWNER "+quoteIdentifier(name)+";")
+	if err != nil {
+		if _, dropErr := srv.conn.ExecContext(context.Background(), "DROP ROLE "+quoteIdentifier(name)+";"); dropErr != nil {
+			return "", "", fmt.Errorf("new tenant: %w (drop role %q: %v)", err, name, dropErr)
+		}
+		return "", "", fmt.Errorf("new tenant: %w", err)
+	}
+	srv.mu.Lock()
+	srv.databases = append(srv.databases, name)
+	srv.mu.Unlock()
+	srv.addCleanup(func() {
+		srv.dropTenant(context.Background(), name)
+	})
+	return srv.dsnAs(name, name), name, nil
+}
+
+// DropTenant drops a database and role created by NewTenant.
+// All connections to the tenant's database must be closed first.
+func (srv *Server) DropTenant(ctx context.Context, role string) error {
+	if err := srv.dropTenant(ctx, role); err != nil {
+		return fmt.Errorf("drop tenant %q: %w", role, err)
+	}
+	return nil
+}
+
+// dropTenant drops the database and role created by NewTenant,
+// skipping either if it has already been dropped.
+func (srv *Server) dropTenant(ctx context.Context, role string) error {
+	if err := srv.dropDatabase(ctx, role); err != nil {
+		return err
+	}
+	if _, err := srv.conn.ExecContext(ctx, "DROP ROLE IF EXISTS "+quoteIdentifier(role)+";"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// dsnAs returns the data source name that connects
+// to the given database as the given role.
+func (srv *Server) dsnAs(role, dbName string) string {
+	u := *srv.baseURL
+	u.User = url.UserPassword(role, "")
+	u.Path = dbName
+	return dsnString(&u)
+}
+
+// RevokeConnect prevents the given role from opening new connections
+// to the database with the given name, to simulate an application
+// losing access to its database. Existing connections are not closed.
+// Since every role may connect to a database by default through the
+// PUBLIC pseudo-role, RevokeConnect also revokes the privilege from PUBLIC,
+// so other roles that rely on PUBLIC cannot connect either until
+// GrantConnect is called for every revoked role.
+// Superusers and the database's owner are not affected by privileges,
+// so if role is empty, RevokeConnect instead disallows all connections
+// to the database, including the superuser's.
+func (srv *Server) RevokeConnect(ctx context.Context, dbName, role string) error {
+	if role == "" {
+		if _, err := srv.conn.ExecContext(ctx, "ALTER DATABASE "+quoteIdentifier(dbName)+" ALLOW_CONNECTIONS false;"); err != nil {
+			return fmt.Errorf("revoke connect on %q: %w", dbName, err)
+		}
+		return nil
+	}
+	var publicCanConnect bool
+	err := srv.conn.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database d, "+
+		"aclexplode(coalesce(d.datacl, acldefault('d', d.datdba))) a "+
+		"WHERE d.datname = $1 AND a.grantee = 0 AND a.privilege_type = 'CONNECT');", dbName).Scan(&publicCanConnect)
+	if err != nil {
+		return fmt.Errorf("revoke connect on %q: %w", dbName, err)
+	}
+	stmt := "REVOKE CONNECT ON DATABASE " + quoteIdentifier(dbName) + " FROM PUBLIC, " + quoteIdentifier(role) + ";"
+	if _, err := srv.conn.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("revoke connect on %q: %w", dbName, err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.connectRevokes == nil {
+		srv.connectRevokes = make(map[string]*connectRevokes)
+	}
+	r := srv.connectRevokes[dbName]
+	if r == nil {
+		r = &connectRevokes{roles: make(map[string]struct{})}
+		srv.connectRevokes[dbName] = r
+	}
+	r.public = r.public || publicCanConnect
+	r.roles[role] = struct{}{}
+	return nil
+}
+
+// connectRevokes records the roles whose CONNECT privilege on a database
+// RevokeConnect revoked, and whether it revoked PUBLIC's privilege.
+type connectRevokes struct {
+	roles  map[string]struct{}
+	public bool
+}
+
+// GrantConnect permits the given role to open connections
+// to the database with the given name, undoing RevokeConnect.
+// Once every role revoked by RevokeConnect has been granted the privilege
+// again, GrantConnect also restores PUBLIC's privilege if RevokeConnect
+// revoked it, so other roles can connect again.
+// If role is empty, GrantConnect allows connections to the database again
+// and grants the privilege to PUBLIC, restoring the default.
+func (srv *Server) GrantConnect(ctx context.Context, dbName, role string) error {
+	if role == "" {
+		stmts := []string{
+			"ALTER DATABASE " + quoteIdentifier(dbName) + " ALLOW_CONNECTIONS true;",
+			"GRANT CONNECT ON DATABASE " + quoteIdentifier(dbName) + " TO PUBLIC;",
+		}
+		for _, stmt := range stmts {
+			if _, err := srv.conn.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("grant connect on %q: %w", dbName, err)
+			}
+		}
+		return nil
+	}
+	grantees := quoteIdentifier(role)
+	srv.mu.Lock()
+	r := srv.connectRevokes[dbName]
+	restorePublic := false
+	if r != nil {
+		if _, revoked := r.roles[role]; revoked && len(r.roles) == 1 && r.public {
+			restorePublic = true
+		}
+	}
+	srv.mu.Unlock()
+	if restorePublic {
+		grantees = "PUBLIC, " + grantees
+	}
+	if _, err := srv.conn.ExecContext(ctx, "GRANT CONNECT ON DATABASE "+quoteIdentifier(dbName)+" TO "+grantees+";"); err != nil {
+		return fmt.Errorf("grant connect on %q: %w", dbName, err)
+	}
+	srv.mu.Lock()
+	if r := srv.connectRevokes[dbName]; r != nil {
+		delete(r.roles, role)
+		if len(r.roles) == 0 {
+			delete(srv.connectRevokes, dbName)
+		}
+	}
+	srv.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestNewTenant(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, role, err := srv.NewTenant(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var currentUser, owner string
+	err = db.QueryRowContext(ctx, `SELECT current_user, pg_get_userbyid(datdba) `+
+		`FROM pg_database WHERE datname = current_database();`).Scan(&currentUser, &owner)
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	if currentUser != role {
+		t.Errorf("current_user = %q; want %q", currentUser, role)
+	}
+	if owner != role {
+		t.Errorf("database owner = %q; want %q", owner, role)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE TABLE foo (id SERIAL PRIMARY KEY);`); err != nil {
+		t.Error("CREATE TABLE:", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if err := srv.DropTenant(ctx, role); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewTenantDropAllDatabases(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	_, role, err := srv.NewTenant(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.DropAllDatabases(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var exists bool
+	err = srv.conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1);`, role).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("tenant database exists after DropAllDatabases")
+	}
+	// The role outlives its database until DropTenant or Cleanup.
+	if err := srv.DropTenant(ctx, role); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRoleSetting(t *testing.T) {
+	cfg := new(roleConfig)
+	RoleSetting("search_path", "app", "public")(cfg)
+	RoleSetting("statement_timeout", "5s")(cfg)
+	got := cfg.statements("app")
+	want := []string{
+		`ALTER ROLE "app" SET "search_path" TO 'app', 'public';`,
+		`ALTER ROLE "app" SET "statement_timeout" TO '5s';`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("statements = %q; want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statements[%d] = %q; want %q", i, got[i], want[i])
+		}
+	}
+
+	t.Run("Server", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+		defer cancel()
+		srv, err := Start(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(srv.Cleanup)
+		err = srv.CreateRole(ctx, "app",
+			RoleSetting("search_path", "app", "public"),
+			RoleSetting("statement_timeout", "5s"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := sql.Open("postgres", srv.dsnAs("app", "postgres"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		for name, want := range map[string]string{"search_path": "app, public", "statement_timeout": "5s"} {
+			var got string
+			if err := db.QueryRowContext(ctx, "SHOW "+name+";").Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("%s = %q; want %q", name, got, want)
+			}
+		}
+
+		if err := srv.CreateRole(ctx, "bad", RoleSetting("work_mem", "nonsense")); err == nil {
+			t.Error("CreateRole with invalid setting did not return an error")
+		}
+		var exists bool
+		if err := srv.conn.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM pg_roles WHERE rolname = 'bad');`).Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if exists {
+			t.Error("role exists after CreateRole failed")
+		}
+	})
+}
+
+func TestRevokeConnect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithDatabases("appdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	if err := srv.CreateRole(ctx, "app"); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.CreateRole(ctx, "other"); err != nil {
+		t.Fatal(err)
+	}
+	ping := func(role string) error {
+		dsn := srv.DSN("appdb")
+		if role != "" {
+			dsn = srv.dsnAs(role, "appdb")
+		}
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return db.PingContext(ctx)
+	}
+
+	if err := srv.RevokeConnect(ctx, "appdb", "app"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ping("app"); err == nil {
+		t.Error("Connected as app after RevokeConnect")
+	}
+	if err := ping("other"); err == nil {
+		t.Error("Connected as other after RevokeConnect revoked PUBLIC")
+	}
+	if err := srv.GrantConnect(ctx, "appdb", "app"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ping("app"); err != nil {
+		t.Error("Connect as app after GrantConnect:", err)
+	}
+	if err := ping("other"); err != nil {
+		t.Error("Connect as other after GrantConnect:", err)
+	}
+
+	if err := srv.RevokeConnect(ctx, "appdb", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ping(""); err == nil {
+		t.Error("Connected as superuser after RevokeConnect for all roles")
+	}
+	if err := srv.GrantConnect(ctx, "appdb", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ping(""); err != nil {
+		t.Error("Connect as superuser after GrantConnect for all roles:", err)
+	}
+}
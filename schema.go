@@ -0,0 +1,122 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// NewSchema creates a schema with a unique name in the database
+// with the given name and returns a *sql.DB whose connections
+// use the schema as their search_path, along with the schema's name.
+// Unqualified tables and other objects created through the *sql.DB
+// land in the schema, so tests sharing one database are isolated
+// without the cost of creating a database per test.
+// The returned *sql.DB is closed by Cleanup if it is still open.
+//
+// Cleanup drops the schema before stopping the server, so the schema
+// does not outlive the server in a data directory that is kept,
+// like one used by Resume. Tests that share a longer-lived server
+// can call DropSchema to remove it sooner.
+func (srv *Server) NewSchema(ctx context.Context, dbName string) (_ *sql.DB, schema string, _ error) {
+	schema, err := randomString(16)
+	if err != nil {
+		return nil, "", fmt.Errorf("new schema: %w", err)
+	}
+	db, err := srv.openDB(srv.DSNWithSearchPath(dbName, schema))
+	if err != nil {
+		return nil, "", fmt.Errorf("new schema: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "CREATE SCHEMA "+quoteIdentifier(schema)+";"); err != nil {
+		db.Close()
+		return nil, "", fmt.Errorf("new schema: %w", err)
+	}
+	srv.addCleanup(func() {
+		db.Close()
+		srv.DropSchema(context.Background(), dbName, schema)
+	})
+	return db, schema, nil
+}
+
+// DropSchema drops the schema with the given name from the database
+// with the given name, along with every object in it.
+func (srv *Server) DropSchema(ctx context.Context, dbName, schema string) error {
+	db, err := sql.Open(srv.cfg.driverName, srv.DSN(dbName))
+	if err != nil {
+		return fmt.Errorf("drop schema %q: %w", schema, err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "DROP SCHEMA "+quoteIdentifier(schema)+" CASCADE;"); err != nil {
+		return fmt.Errorf("drop schema %q: %w", schema, err)
+	}
+	return nil
+}
+
+// RecreatePublicSchema drops the public schema of the database
+// with the given data source name, along with every object in it,
+// and creates an empty public schema in its place.
+// The new schema has the same owner, privileges, and comment
+// as in a new database: starting with PostgreSQL 15,
+// it is owned by pg_database_owner and other roles can use but not
+// create objects in it; in earlier versions, it is owned by the superuser
+// and every role can create objects in it.
+// This wipes a database's objects faster than dropping and recreating it.
+// The data source name must connect as the superuser, like those from DSN.
+func (srv *Server) RecreatePublicSchema(ctx context.Context, dsn string) (err error) {
+	version, err := srv.majorVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("recreate public schema: %w", err)
+	}
+	stmts := []string{
+		"DROP SCHEMA IF EXISTS public CASCADE;",
+		"CREATE SCHEMA public;",
+		"COMMENT ON SCHEMA public IS 'standard public schema';",
+	}
+	if version >= 15 {
+		stmts = append(stmts,
+			"ALTER SCHEMA public OWNER TO pg_database_owner;",
+			"GRANT USAGE ON SCHEMA public TO PUBLIC;")
+	} else {
+		stmts = append(stmts, "GRANT ALL ON SCHEMA public TO PUBLIC;")
+	}
+
+	db, err := sql.Open(srv.cfg.driverName, dsn)
+	if err != nil {
+		return fmt.Errorf("recreate public schema: %w", err)
+	}
+	defer db.Close()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("recreate public schema: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("recreate public schema: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("recreate public schema: %w", err)
+	}
+	return nil
+}
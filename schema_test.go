@@ -0,0 +1,164 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewSchema(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbName := strings.TrimPrefix(mustParseURL(t, dsn).Path, "/")
+
+	db, schema, err := srv.NewSchema(ctx, dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE TABLE foo (id SERIAL PRIMARY KEY);`); err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	err = db.QueryRowContext(ctx, `SELECT table_schema FROM information_schema.tables WHERE table_name = 'foo';`).Scan(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != schema {
+		t.Errorf("table created in schema %q; want %q", got, schema)
+	}
+
+	if err := srv.DropSchema(ctx, dbName, schema); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	err = db.QueryRowContext(ctx, `SELECT count(*) FROM information_schema.schemata WHERE schema_name = $1;`, schema).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("schema %q still exists after DropSchema", schema)
+	}
+}
+
+func TestNewSchemaCleanup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dataDir := filepath.Join(makeTempDir(t), "data")
+	if err := srv.SnapshotCluster(ctx, dataDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cleanup leaves a resumed server's data directory in place,
+	// so the schema would survive if Cleanup did not drop it.
+	resumed, err := Resume(ctx, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, schema, err := resumed.NewSchema(ctx, "postgres")
+	if err != nil {
+		resumed.Cleanup()
+		t.Fatal(err)
+	}
+	resumed.Cleanup()
+
+	resumed, err = Resume(ctx, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(resumed.Cleanup)
+	var n int
+	err = resumed.conn.QueryRowContext(ctx, `SELECT count(*) FROM pg_namespace WHERE nspname = $1;`, schema).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("schema %q still exists after Cleanup", schema)
+	}
+}
+
+func TestRecreatePublicSchema(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	const describeSchema = `SELECT pg_get_userbyid(nspowner), coalesce(nspacl::text, ''), ` +
+		`coalesce(obj_description(oid, 'pg_namespace'), '') ` +
+		`FROM pg_namespace WHERE nspname = 'public';`
+	var wantOwner, wantACL, wantComment string
+	if err := db.QueryRowContext(ctx, describeSchema).Scan(&wantOwner, &wantACL, &wantComment); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE TABLE foo (id SERIAL PRIMARY KEY);`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.RecreatePublicSchema(ctx, dsn); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	err = db.QueryRowContext(ctx, `SELECT count(*) FROM pg_class c `+
+		`JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = 'public';`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("public schema has %d relations after recreating; want 0", n)
+	}
+	var owner, acl, comment string
+	if err := db.QueryRowContext(ctx, describeSchema).Scan(&owner, &acl, &comment); err != nil {
+		t.Fatal(err)
+	}
+	if owner != wantOwner {
+		t.Errorf("owner = %q; want %q", owner, wantOwner)
+	}
+	if acl != wantACL {
+		t.Errorf("privileges = %q; want %q", acl, wantACL)
+	}
+	if comment != wantComment {
+		t.Errorf("comment = %q; want %q", comment, wantComment)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ownedSequencesQuery lists the sequences owned by table columns,
+// such as those of serial and identity columns,
+// along with the quoted names of their table and column
+// and their start values.
+const ownedSequencesQuery = `SELECT s.oid::regclass::text, t.oid::regclass::text, quote_ident(a.attname), seq.seqstart
+FROM pg_class s
+JOIN pg_sequence seq ON seq.seqrelid = s.oid
+JOIN pg_depend d ON d.classid = 'pg_class'::regclass AND d.objid = s.oid
+	AND d.refclassid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+JOIN pg_class t ON t.oid = d.refobjid
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = d.refobjsubid
+WHERE s.relkind = 'S';`
+
+// ResetSequences sets each sequence owned by a table column
+// in the database with the given data source name,
+// like those of serial and identity columns,
+// so that the next value it returns is greater than
+// the column's current maximum value.
+// If the column's table is empty, the sequence restarts at its start value.
+// This brings sequences back in line after data is reloaded
+// with explicit values. ResetSequences requires PostgreSQL 10 or later.
+func (srv *Server) ResetSequences(ctx context.Context, dsn string) (err error) {
+	db, err := sql.Open(srv.cfg.driverName, dsn)
+	if err != nil {
+		return fmt.Errorf("reset sequences: %w", err)
+	}
+	defer db.Close()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("reset sequences: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	type ownedSequence struct {
+		seq, table, column string
+		start              int64
+	}
+	rows, err := tx.QueryContext(ctx, ownedSequencesQuery)
+	if err != nil {
+		return fmt.Errorf("reset sequences: %w", err)
+	}
+	var seqs []ownedSequence
+	for rows.Next() {
+		var s ownedSequence
+		if err := rows.Scan(&s.seq, &s.table, &s.column, &s.start); err != nil {
+			rows.Close()
+			return fmt.Errorf("reset sequences: %w", err)
+		}
+		seqs = append(seqs, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reset sequences: %w", err)
+	}
+
+	for _, s := range seqs {
+		// The regclass text and quote_ident results are already quoted.
+		q := "SELECT setval($1::regclass, COALESCE(max(" + s.column + "), $2), max(" + s.column + ") IS NOT NULL) FROM " + s.table + ";"
+		if _, err := tx.ExecContext(ctx, q, s.seq, s.start); err != nil {
+			return fmt.Errorf("reset sequences: %s: %w", s.seq, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("reset sequences: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResetSequences(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := srv.openDB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE "Loaded" (id SERIAL PRIMARY KEY);`,
+		`INSERT INTO "Loaded" (id) VALUES (1), (2), (42);`,
+		`CREATE TABLE empty (id INTEGER GENERATED BY DEFAULT AS IDENTITY (START WITH 100) PRIMARY KEY);`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := srv.ResetSequences(ctx, dsn); err != nil {
+		t.Fatal(err)
+	}
+	var got int
+	if err := db.QueryRowContext(ctx, `INSERT INTO "Loaded" DEFAULT VALUES RETURNING id;`).Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != 43 {
+		t.Errorf("Next id in loaded table = %d; want 43", got)
+	}
+	if err := db.QueryRowContext(ctx, `INSERT INTO empty DEFAULT VALUES RETURNING id;`).Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != 100 {
+		t.Errorf("Next id in empty table = %d; want 100", got)
+	}
+}
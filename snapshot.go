@@ -0,0 +1,216 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Checkpoint forces a checkpoint, writing all modified data to disk.
+func (srv *Server) Checkpoint(ctx context.Context) error {
+	if _, err := srv.conn.ExecContext(ctx, "CHECKPOINT;"); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	return nil
+}
+
+// SnapshotCluster copies the server's entire data directory into dir,
+// which is created if it does not exist. The server is checkpointed and
+// stopped for the duration of the copy, so all connections to the server
+// are broken. RestoreCluster can later reset the server to the snapshot,
+// including cluster-wide objects like roles and tablespaces.
+// The contents of tablespaces, which are stored outside the data directory,
+// are copied into the snapshot too.
+//
+// SnapshotCluster must not be called concurrently with other methods
+// on the server.
+func (srv *Server) SnapshotCluster(ctx context.Context, dir string) error {
+	if err := srv.Checkpoint(ctx); err != nil {
+		return fmt.Errorf("snapshot cluster: %w", err)
+	}
+	err := srv.restart(ctx, func() error {
+		return snapshotDataDir(dir, srv.dataDir)
+	})
+	if err != nil {
+		return fmt.Errorf("snapshot cluster: %w", err)
+	}
+	return nil
+}
+
+// RestoreCluster replaces the server's data directory
+// with a snapshot created by SnapshotCluster and restarts the server.
+// The tablespaces in the snapshot are restored to their original locations,
+// replacing their current contents.
+// All connections to the server are broken.
+//
+// RestoreCluster must not be called concurrently with other methods
+// on the server.
+func (srv *Server) RestoreCluster(ctx context.Context, dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, "PG_VERSION")); err != nil {
+		return fmt.Errorf("restore cluster: %s is not a snapshot: %w", dir, err)
+	}
+	err := srv.restart(ctx, func() error {
+		dataDir := srv.dataDir
+		if err := os.RemoveAll(dataDir); err != nil {
+			return err
+		}
+		return restoreDataDir(dataDir, dir)
+	})
+	if err != nil {
+		return fmt.Errorf("restore cluster: %w", err)
+	}
+	return nil
+}
+
+// tablespaceMapFile is the name of the file in a snapshot
+// that lists the original locations of the snapshot's tablespaces.
+const tablespaceMapFile = "postgrestest_tablespaces"
+
+// snapshotDataDir copies the data directory src into dst.
+// Tablespaces are linked from src's pg_tblspc directory
+// to directories elsewhere, so their contents are copied
+// into directories in dst's pg_tblspc instead, and their original locations
+// are recorded in dst's tablespace map file.
+func snapshotDataDir(dst, src string) error {
+	tblspc := filepath.Join(src, "pg_tblspc")
+	tablespaceMap := new(strings.Builder)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if filepath.Dir(path) == tblspc && info.Mode()&os.ModeSymlink != 0 {
+			location, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if !filepath.IsAbs(location) {
+				location = filepath.Join(tblspc, location)
+			}
+			fmt.Fprintf(tablespaceMap, "%s\t%s\n", info.Name(), location)
+			return copyDir(target, location)
+		}
+		return copyEntry(target, path, info)
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dst, tablespaceMapFile), []byte(tablespaceMap.String()), 0600)
+}
+
+// restoreDataDir copies a snapshot created by snapshotDataDir
+// into the data directory dst, copying tablespaces back to their
+// original locations and linking them from dst's pg_tblspc directory.
+func restoreDataDir(dst, snapshot string) error {
+	mapData, err := ioutil.ReadFile(filepath.Join(snapshot, tablespaceMapFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	locations := make(map[string]string)
+	for _, line := range strings.Split(string(mapData), "\n") {
+		if i := strings.IndexByte(line, '\t'); i != -1 {
+			locations[line[:i]] = line[i+1:]
+		}
+	}
+	tblspc := filepath.Join(snapshot, "pg_tblspc")
+	return filepath.Walk(snapshot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(snapshot, path)
+		if err != nil {
+			return err
+		}
+		if rel == tablespaceMapFile {
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		if location := locations[info.Name()]; location != "" && filepath.Dir(path) == tblspc {
+			if err := os.RemoveAll(location); err != nil {
+				return err
+			}
+			if err := copyDir(location, path); err != nil {
+				return err
+			}
+			if err := os.Symlink(location, target); err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		return copyEntry(target, path, info)
+	})
+}
+
+// copyDir recursively copies the contents of src into dst,
+// preserving permissions. Files must not already exist in dst.
+func copyDir(dst, src string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return copyEntry(filepath.Join(dst, rel), path, info)
+	})
+}
+
+// copyEntry copies the file or directory at src, described by info,
+// to dst. The contents of directories are not copied.
+func copyEntry(dst, src string, info os.FileInfo) error {
+	switch {
+	case info.IsDir():
+		if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		// MkdirAll does nothing if dst already exists.
+		return os.Chmod(dst, info.Mode().Perm())
+	case info.Mode().IsRegular():
+		return copyFile(dst, src, info.Mode().Perm())
+	default:
+		return fmt.Errorf("copy %s: not a regular file or directory", src)
+	}
+}
+
+func copyFile(dst, src string, perm os.FileMode) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	closeErr := w.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,286 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotCluster(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	snapshotDir := filepath.Join(makeTempDir(t), "snapshot")
+	if err := srv.SnapshotCluster(ctx, snapshotDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.CreateRole(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.RestoreCluster(ctx, snapshotDir); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("postgres", srv.DefaultDatabase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM pg_roles WHERE rolname = 'alice';`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("Role created after snapshot exists after restore")
+	}
+	if err := srv.Err(); err != nil {
+		t.Error("srv.Err() =", err)
+	}
+}
+
+func TestSnapshotClusterFailureKeepsServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	// A file that already exists in the destination makes the copy fail.
+	snapshotDir := makeTempDir(t)
+	if err := ioutil.WriteFile(filepath.Join(snapshotDir, "PG_VERSION"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.SnapshotCluster(ctx, snapshotDir); err == nil {
+		t.Error("SnapshotCluster into a directory with conflicting files did not return an error")
+	}
+	if err := srv.Err(); err != nil {
+		t.Error("srv.Err() =", err)
+	}
+	if _, err := srv.NewDatabase(ctx); err != nil {
+		t.Error("server unusable after failed snapshot:", err)
+	}
+}
+
+func TestRestartFailure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	// A canceled context makes starting the server again fail.
+	canceled, cancelRestart := context.WithCancel(ctx)
+	cancelRestart()
+	if err := srv.restart(canceled, func() error { return nil }); err == nil {
+		t.Fatal("restart with canceled context did not return an error")
+	}
+	select {
+	case <-srv.Done():
+	default:
+		t.Fatal("Done() not closed after failed restart")
+	}
+	if srv.Err() == nil {
+		t.Error("srv.Err() = <nil> after failed restart")
+	}
+	// Operations that restart the server must not try again.
+	if err := srv.SnapshotCluster(ctx, filepath.Join(makeTempDir(t), "snapshot")); err == nil {
+		t.Error("SnapshotCluster after failed restart did not return an error")
+	}
+}
+
+func TestResume(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	if err := srv.CreateRole(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	snapshotDir := filepath.Join(makeTempDir(t), "snapshot")
+	if err := srv.SnapshotCluster(ctx, snapshotDir); err != nil {
+		t.Fatal(err)
+	}
+	if srv.Resumed() {
+		t.Error("srv.Resumed() = true for server created by Start")
+	}
+
+	resumed, err := Resume(ctx, snapshotDir, WithDatabases("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(resumed.Cleanup)
+	if !resumed.Resumed() {
+		t.Error("Resumed() = false for server created by Resume")
+	}
+	var n int
+	if err := resumed.conn.QueryRowContext(ctx, `SELECT count(*) FROM pg_roles WHERE rolname = 'alice';`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Error("Role created before snapshot does not exist in resumed server")
+	}
+	db, err := sql.Open("postgres", resumed.DSN("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResumeMismatchedOptions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	snapshotDir := filepath.Join(makeTempDir(t), "snapshot")
+	if err := srv.SnapshotCluster(ctx, snapshotDir); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := Resume(ctx, snapshotDir, WithWALSegSize(64))
+	if err == nil {
+		resumed.Cleanup()
+		t.Fatal("Resume with different initdb options did not return an error")
+	}
+}
+
+func TestResumeMismatchedVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	// No installation is this old, so the installed pg_ctl never matches.
+	dataDir := makeTempDir(t)
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("8.4\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeManifest(dataDir, newStartConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := Resume(ctx, dataDir)
+	if err == nil {
+		resumed.Cleanup()
+		t.Fatal("Resume with data from a different PostgreSQL version did not return an error")
+	}
+	if want := "initialized by PostgreSQL 8.4"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Resume error = %v; want to contain %q", err, want)
+	}
+}
+
+func TestSnapshotClusterWithTablespace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	if _, err := srv.CreateTablespace(ctx, "fast"); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("postgres", srv.DefaultDatabase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `CREATE TABLE foo (id INTEGER) TABLESPACE fast; INSERT INTO foo VALUES (1);`); err != nil {
+		t.Fatal(err)
+	}
+	snapshotDir := filepath.Join(makeTempDir(t), "snapshot")
+	if err := srv.SnapshotCluster(ctx, snapshotDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO foo VALUES (2);`); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.RestoreCluster(ctx, snapshotDir); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM foo;`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("foo has %d rows after restore; want 1", n)
+	}
+	if err := srv.Err(); err != nil {
+		t.Error("srv.Err() =", err)
+	}
+}
+
+func TestSnapshotDataDirTablespace(t *testing.T) {
+	root := makeTempDir(t)
+	dataDir := filepath.Join(root, "data")
+	location := filepath.Join(root, "tablespaces", "fast")
+	for _, dir := range []string{filepath.Join(dataDir, "pg_tblspc"), location} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(location, "table"), []byte("before"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(location, filepath.Join(dataDir, "pg_tblspc", "16384")); err != nil {
+		t.Skip("cannot create symlinks:", err)
+	}
+
+	snapshotDir := filepath.Join(root, "snapshot")
+	if err := snapshotDataDir(snapshotDir, dataDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(location, "table"), []byte("after"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(dataDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreDataDir(dataDir, snapshotDir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(dataDir, "pg_tblspc", "16384", "table"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "before" {
+		t.Errorf("restored tablespace file = %q; want %q", got, "before")
+	}
+	if link, err := os.Readlink(filepath.Join(dataDir, "pg_tblspc", "16384")); err != nil {
+		t.Error(err)
+	} else if link != location {
+		t.Errorf("restored tablespace links to %q; want %q", link, location)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, tablespaceMapFile)); !os.IsNotExist(err) {
+		t.Errorf("tablespace map copied into data directory (err = %v)", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// ApplySQLFile runs the SQL script in the file at path against the database
+// with the given data source name, inside a transaction.
+// It is equivalent to calling ApplySQLFiles with a single path.
+func (srv *Server) ApplySQLFile(ctx context.Context, dsn, path string) error {
+	return srv.ApplySQLFiles(ctx, dsn, path)
+}
+
+// RunPsqlScript runs the psql script in the file at scriptPath
+// against the database with the given name, stopping at the first error.
+// Unlike ApplySQLFile, the script may use psql's backslash commands,
+// like \copy, \i, and \set, and is not run inside a transaction
+// unless it starts one itself. As in psql, relative paths in \copy and \i
+// are resolved against the current directory,
+// which for a test is its package's directory;
+// \ir resolves paths relative to the script.
+func (srv *Server) RunPsqlScript(ctx context.Context, dbName, scriptPath string) error {
+	err := runCommandContext(ctx, "psql",
+		"--no-psqlrc",
+		"--quiet",
+		"--set=ON_ERROR_STOP=1",
+		"--file="+scriptPath,
+		"--dbname="+srv.DSN(dbName))
+	if err != nil {
+		return fmt.Errorf("run psql script %s: %w", scriptPath, err)
+	}
+	return nil
+}
+
+// ApplySQLFiles runs the SQL scripts in the files at the given paths,
+// in order, against the database with the given data source name.
+// All the scripts are run in a single transaction:
+// if any statement fails, none of the scripts' changes are kept.
+//
+// Some statements cannot run inside a transaction, like
+// CREATE DATABASE, CREATE INDEX CONCURRENTLY, VACUUM, and ALTER SYSTEM.
+// ApplySQLFiles returns an error without running any scripts
+// if it finds such a statement. Scripts must not contain
+// transaction control statements like BEGIN and COMMIT.
+func (srv *Server) ApplySQLFiles(ctx context.Context, dsn string, paths ...string) (err error) {
+	scripts := make([]string, 0, len(paths))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("apply sql: %w", err)
+		}
+		script := string(data)
+		if stmt := findNonTransactional(script); stmt != "" {
+			return fmt.Errorf("apply sql: %s: %s cannot run inside a transaction", path, stmt)
+		}
+		scripts = append(scripts, script)
+	}
+
+	db, err := sql.Open(srv.cfg.driverName, dsn)
+	if err != nil {
+		return fmt.Errorf("apply sql: %w", err)
+	}
+	defer db.Close()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("apply sql: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	for i, script := range scripts {
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			return fmt.Errorf("apply sql: %s: %w", paths[i], err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("apply sql: %w", err)
+	}
+	return nil
+}
+
+var (
+	sqlCommentPattern = regexp.MustCompile(`(?s)--[^\n]*|/\*.*?\*/`)
+
+	nonTransactionalPattern = regexp.MustCompile(`(?i)\b(?:` +
+		`(?:CREATE|DROP)\s+DATABASE\b` +
+		`|(?:CREATE|DROP)\s+TABLESPACE\b` +
+		`|CREATE\s+(?:UNIQUE\s+)?INDEX\s+CONCURRENTLY\b` +
+		`|DROP\s+INDEX\s+CONCURRENTLY\b` +
+		`|REINDEX\s+[^;]*\bCONCURRENTLY\b` +
+		`|VACUUM\b` +
+		`|ALTER\s+SYSTEM\b` +
+		`|(?:BEGIN|COMMIT|ROLLBACK|START\s+TRANSACTION)\s*;` +
+		`)`)
+)
+
+// findNonTransactional returns the first statement in script
+// that cannot run inside ApplySQLFiles's transaction,
+// or the empty string if there is none.
+// It does not parse string literals, so it may report false positives
+// for statements that only appear inside strings.
+func findNonTransactional(script string) string {
+	script = sqlCommentPattern.ReplaceAllString(script, " ")
+	match := nonTransactionalPattern.FindString(script)
+	if match == "" {
+		return ""
+	}
+	match = strings.TrimSuffix(strings.TrimSpace(match), ";")
+	return strings.ToUpper(strings.Join(strings.Fields(match), " "))
+}
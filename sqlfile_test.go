@@ -0,0 +1,166 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplySQLFiles(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dir := makeTempDir(t)
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	schema := writeFile("001_schema.sql", `CREATE TABLE foo (id INTEGER PRIMARY KEY);`)
+	data := writeFile("002_data.sql", `INSERT INTO foo VALUES (1), (2);`)
+	bad := writeFile("003_bad.sql", `INSERT INTO foo VALUES (3); INSERT INTO bork VALUES (1);`)
+
+	t.Run("Failure", func(t *testing.T) {
+		dsn, err := srv.CreateDatabase(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.ApplySQLFiles(ctx, dsn, schema, data, bad); err == nil {
+			t.Error("ApplySQLFiles with failing script did not return an error")
+		}
+		db, err := srv.openDB(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		var exists bool
+		if err := db.QueryRowContext(ctx, `SELECT to_regclass('foo') IS NOT NULL;`).Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if exists {
+			t.Error("Table from first script exists after failed apply")
+		}
+	})
+	t.Run("Success", func(t *testing.T) {
+		dsn, err := srv.CreateDatabase(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.ApplySQLFiles(ctx, dsn, schema, data); err != nil {
+			t.Fatal(err)
+		}
+		db, err := srv.openDB(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		var n int
+		if err := db.QueryRowContext(ctx, `SELECT count(*) FROM foo;`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("foo has %d rows; want 2", n)
+		}
+	})
+}
+
+func TestRunPsqlScript(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbName := strings.TrimPrefix(mustParseURL(t, dsn).Path, "/")
+	dir := makeTempDir(t)
+	csvPath := filepath.Join(dir, "foo.csv")
+	if err := ioutil.WriteFile(csvPath, []byte("1,apple\n2,banana\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	scriptPath := filepath.Join(dir, "seed.sql")
+	script := "\\set tbl foo\n" +
+		"CREATE TABLE :tbl (id INTEGER PRIMARY KEY, name TEXT);\n" +
+		"\\copy foo FROM " + quoteLiteral(csvPath) + " WITH (FORMAT csv)\n"
+	if err := ioutil.WriteFile(scriptPath, []byte(script), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.RunPsqlScript(ctx, dbName, scriptPath); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM foo;`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("foo has %d rows; want 2", n)
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		badPath := filepath.Join(dir, "bad.sql")
+		if err := ioutil.WriteFile(badPath, []byte("SELECT nonsense;\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.RunPsqlScript(ctx, dbName, badPath); err == nil {
+			t.Error("RunPsqlScript with invalid script did not return an error")
+		}
+	})
+}
+
+func TestFindNonTransactional(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{script: `CREATE TABLE foo (id INTEGER);`, want: ""},
+		{script: `CREATE TABLE vacuum_log (id INTEGER);`, want: ""},
+		{script: `-- VACUUM later
+CREATE TABLE foo (id INTEGER);`, want: ""},
+		{script: `DO $$ BEGIN PERFORM 1; END $$;`, want: ""},
+		{script: `CREATE TABLE foo (id INTEGER);
+create  unique index
+  concurrently foo_idx ON foo (id);`, want: "CREATE UNIQUE INDEX CONCURRENTLY"},
+		{script: `VACUUM ANALYZE foo;`, want: "VACUUM"},
+		{script: `CREATE DATABASE bar;`, want: "CREATE DATABASE"},
+		{script: `BEGIN; CREATE TABLE foo (id INTEGER); COMMIT;`, want: "BEGIN"},
+	}
+	for _, test := range tests {
+		if got := findNonTransactional(test.script); got != test.want {
+			t.Errorf("findNonTransactional(%q) = %q; want %q", test.script, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CreateTablespace creates a tablespace with the given name
+// and returns the directory where it stores its data.
+// The directory is inside the server's temporary directory,
+// so it is removed by Cleanup. Since the name is used as the directory's name,
+// it must be a single path element.
+func (srv *Server) CreateTablespace(ctx context.Context, name string) (location string, err error) {
+	if !isPathElement(name) {
+		return "", fmt.Errorf("create tablespace %q: name is not a single path element", name)
+	}
+	location = filepath.Join(srv.dir, "tablespaces", name)
+	if err := os.MkdirAll(location, 0700); err != nil {
+		return "", fmt.Errorf("create tablespace %q: %w", name, err)
+	}
+	_, err = srv.conn.ExecContext(ctx, "CREATE TABLESPACE "+quoteIdentifier(name)+
+		" LOCATION "+quoteLiteral(location)+";")
+	if err != nil {
+		os.Remove(location)
+		return "", fmt.Errorf("create tablespace %q: %w", name, err)
+	}
+	return location, nil
+}
+
+// isPathElement reports whether name can be used as the name of a file
+// in a directory without referring to another directory.
+func isPathElement(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsRune(name, '/') && !strings.ContainsRune(name, filepath.Separator) &&
+		filepath.VolumeName(name) == ""
+}
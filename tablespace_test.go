@@ -0,0 +1,110 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateTablespace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	if _, err := srv.CreateTablespace(ctx, "fast"); err != nil {
+		t.Fatal(err)
+	}
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `CREATE TABLE foo (id SERIAL PRIMARY KEY) TABLESPACE fast;`); err != nil {
+		t.Fatal(err)
+	}
+	var spcname string
+	err = db.QueryRowContext(ctx, `SELECT t.spcname FROM pg_class c `+
+		`JOIN pg_tablespace t ON t.oid = c.reltablespace `+
+		`WHERE c.relname = 'foo';`).Scan(&spcname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spcname != "fast" {
+		t.Errorf("foo is in tablespace %q; want %q", spcname, "fast")
+	}
+}
+
+func TestWithDefaultTablespace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithDefaultTablespace("fast"), WithTempTablespaces("scratch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	for _, name := range []string{"fast", "scratch"} {
+		if _, err := srv.CreateTablespace(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := srv.NewDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE foo (id SERIAL PRIMARY KEY);`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, `CREATE TEMPORARY TABLE bar (id INTEGER);`); err != nil {
+		t.Fatal(err)
+	}
+	for relname, want := range map[string]string{"foo": "fast", "bar": "scratch"} {
+		var spcname string
+		err = conn.QueryRowContext(ctx, `SELECT t.spcname FROM pg_class c `+
+			`JOIN pg_tablespace t ON t.oid = c.reltablespace `+
+			`WHERE c.relname = $1;`, relname).Scan(&spcname)
+		if err != nil {
+			t.Fatalf("tablespace of %s: %v", relname, err)
+		}
+		if spcname != want {
+			t.Errorf("%s is in tablespace %q; want %q", relname, spcname, want)
+		}
+	}
+}
+
+func TestCreateTablespaceInvalidName(t *testing.T) {
+	srv := new(Server)
+	for _, name := range []string{"", ".", "..", "../fast", "a/b", string(filepath.Separator) + "fast"} {
+		if _, err := srv.CreateTablespace(context.Background(), name); err == nil {
+			t.Errorf("CreateTablespace(ctx, %q) did not return an error", name)
+		}
+	}
+}
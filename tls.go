@@ -0,0 +1,153 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// File names of the TLS certificate and key in the server's directory.
+const (
+	tlsCertFileName = "server.crt"
+	tlsKeyFileName  = "server.key"
+)
+
+// hbaTLS returns the pg_hba.conf used by servers that listen for TLS
+// connections over TCP. Connections over the Unix socket, including the
+// server's administrative connection, are trusted as usual, but connections
+// over TCP must use TLS. With channel binding, the superuser must also
+// authenticate with SCRAM over TCP.
+func hbaTLS(channelBinding bool) string {
+	hba := "local all all trust\n" +
+		"local replication all trust\n"
+	if channelBinding {
+		hba += "hostssl all " + superuserName + " 127.0.0.1/32 scram-sha-256\n"
+	}
+	return hba + "hostssl all all 127.0.0.1/32 trust\n"
+}
+
+// WithChannelBinding configures the server to support SCRAM authentication
+// with channel binding, for testing drivers' support for it.
+// In addition to its Unix socket, the server listens on a TCP port
+// on the loopback interface that only accepts TLS connections,
+// using a self-signed certificate. Over TCP, the superuser must
+// authenticate with SCRAM-SHA-256 using a random password.
+// Use Server.ChannelBindingDSN to connect with channel binding.
+// Unless WithSSLMode chooses a mode that does not require TLS,
+// other data source names also connect over TCP, with sslmode "require"
+// and the superuser's password.
+// Channel binding requires PostgreSQL 11 or later.
+func WithChannelBinding() StartOption {
+	return func(cfg *startConfig) {
+		cfg.channelBinding = true
+		cfg.settings["password_encryption"] = "scram-sha-256"
+	}
+}
+
+// ChannelBindingDSN returns a data source name that connects to the
+// database with the given name over TCP, using TLS and SCRAM authentication
+// with channel_binding=require. The server must have been started with
+// WithChannelBinding. The data source name includes the parameters set by
+// WithDSNParams, but its sslmode is always "require". Only drivers that
+// support channel binding, like libpq 13 and later, accept the
+// channel_binding parameter.
+func (srv *Server) ChannelBindingDSN(dbName string) string {
+	if srv.tcpPort == 0 {
+		panic("postgrestest: ChannelBindingDSN called on server started without WithChannelBinding")
+	}
+	query := make(url.Values)
+	for k, v := range srv.cfg.dsnParams {
+		query.Set(k, v)
+	}
+	query.Set("sslmode", "require")
+	query.Set("channel_binding", "require")
+	u := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(superuserName, srv.password),
+		Host:     net.JoinHostPort("127.0.0.1", strconv.Itoa(srv.tcpPort)),
+		Path:     "/" + dbName,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// freeTCPPort returns a TCP port on the loopback interface
+// that is not currently in use.
+func freeTCPPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	if err := l.Close(); err != nil {
+		return 0, err
+	}
+	return port, nil
+}
+
+// writeTLSCert writes a new self-signed certificate for the loopback
+// interface and its private key into dir.
+func writeTLSCert(dir string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    now.Add(-1 * time.Hour),
+		NotAfter:     now.Add(30 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	if err := ioutil.WriteFile(filepath.Join(dir, tlsCertFileName), certPEM, 0600); err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(filepath.Join(dir, tlsKeyFileName), keyPEM, 0600); err != nil {
+		return err
+	}
+	return nil
+}
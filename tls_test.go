@@ -0,0 +1,80 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestWithChannelBinding(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithChannelBinding())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn := srv.ChannelBindingDSN("postgres")
+
+	t.Run("TLS", func(t *testing.T) {
+		// github.com/lib/pq does not support channel binding,
+		// but it can check that the server requires TLS and SCRAM.
+		u := mustParseURL(t, dsn)
+		q := u.Query()
+		q.Del("channel_binding")
+		u.RawQuery = q.Encode()
+		db, err := sql.Open("postgres", u.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		var ssl bool
+		if err := db.QueryRowContext(ctx, `SELECT ssl FROM pg_stat_ssl WHERE pid = pg_backend_pid();`).Scan(&ssl); err != nil {
+			t.Fatal(err)
+		}
+		if !ssl {
+			t.Error("Connection does not use TLS")
+		}
+
+		u.User = nil
+		noPassword, err := sql.Open("postgres", u.String()+"&user=postgres")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer noPassword.Close()
+		if err := noPassword.PingContext(ctx); err == nil {
+			t.Error("Connecting without password succeeded")
+		}
+	})
+	t.Run("psql", func(t *testing.T) {
+		psql, err := commandContext(ctx, "psql", "--no-psqlrc", "--tuples-only", "--no-align",
+			"--command=SELECT 1;", dsn)
+		if err != nil {
+			t.Skip("psql not found:", err)
+		}
+		out, err := psql.CombinedOutput()
+		if strings.Contains(string(out), `invalid connection option "channel_binding"`) {
+			t.Skip("psql does not support channel binding")
+		}
+		if err != nil {
+			t.Fatalf("psql: %v\n%s", err, out)
+		}
+	})
+}
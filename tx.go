@@ -0,0 +1,115 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// WithSavepoint runs fn inside a savepoint of tx and then rolls back
+// to the savepoint, discarding fn's changes while leaving the rest of the
+// transaction intact. This gives each step of a transaction-per-test pattern
+// its own isolation. The rollback happens even if fn fails or leaves the
+// transaction in an aborted state, so tx remains usable afterward.
+// WithSavepoint returns fn's error, if any.
+func (srv *Server) WithSavepoint(ctx context.Context, tx *sql.Tx, fn func() error) error {
+	name, err := randomString(16)
+	if err != nil {
+		return fmt.Errorf("savepoint: %w", err)
+	}
+	name = quoteIdentifier(name)
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name+";"); err != nil {
+		return fmt.Errorf("savepoint: %w", err)
+	}
+	fnErr := fn()
+	// Use a fresh context: if ctx is done, the savepoint still needs to be
+	// rolled back for tx to be usable.
+	if _, err := tx.ExecContext(context.Background(), "ROLLBACK TO SAVEPOINT "+name+";"); err != nil {
+		if fnErr != nil {
+			return fnErr
+		}
+		return fmt.Errorf("roll back savepoint: %w", err)
+	}
+	if _, err := tx.ExecContext(context.Background(), "RELEASE SAVEPOINT "+name+";"); err != nil && fnErr == nil {
+		return fmt.Errorf("release savepoint: %w", err)
+	}
+	return fnErr
+}
+
+// TestTx begins a transaction on the database with the given name
+// for the duration of the test and registers a cleanup with tb
+// that rolls it back, so that tests sharing one database
+// do not see each other's changes. Inside the transaction,
+// the current role is a new role unique to the test,
+// and the search_path starts with a new schema owned by that role,
+// followed by the public schema, so unqualified tables that the test
+// creates do not collide with other tests' tables.
+// The role is not a superuser: it has the privileges granted to PUBLIC
+// and owns what it creates. The role is dropped after the rollback.
+// TestTx calls tb.Fatal if the transaction cannot be started.
+//
+// Transaction-scoped tests have limits that per-test databases do not:
+// the test cannot commit, statements that cannot run inside a transaction
+// (like CREATE DATABASE or VACUUM) fail, an error aborts the rest of
+// the transaction unless the test uses WithSavepoint, DDL on shared
+// tables takes locks that block other tests until the rollback,
+// and shared tables must grant privileges to PUBLIC to be usable.
+func (srv *Server) TestTx(tb TB, dbName string) *sql.Tx {
+	tb.Helper()
+	role, err := randomString(16)
+	if err != nil {
+		tb.Fatal("postgrestest: begin test transaction:", err)
+	}
+	if err := srv.CreateRole(context.Background(), role); err != nil {
+		tb.Fatal("postgrestest: begin test transaction:", err)
+	}
+	tb.Cleanup(func() {
+		// Registered first so that it runs after the rollback,
+		// when the role no longer owns the schema.
+		srv.conn.ExecContext(context.Background(), "DROP ROLE IF EXISTS "+quoteIdentifier(role)+";")
+	})
+	db, err := sql.Open(srv.cfg.driverName, srv.DSN(dbName))
+	if err != nil {
+		tb.Fatal("postgrestest: begin test transaction:", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		tb.Fatal("postgrestest: begin test transaction:", err)
+	}
+	tb.Cleanup(func() {
+		tx.Rollback()
+		db.Close()
+	})
+	schema, err := randomString(16)
+	if err != nil {
+		tb.Fatal("postgrestest: begin test transaction:", err)
+	}
+	schema = quoteIdentifier(schema)
+	if _, err := tx.Exec("CREATE SCHEMA " + schema + " AUTHORIZATION " + quoteIdentifier(role) + ";"); err != nil {
+		tb.Fatal("postgrestest: begin test transaction:", err)
+	}
+	if _, err := tx.Exec("SET LOCAL ROLE " + quoteIdentifier(role) + ";"); err != nil {
+		tb.Fatal("postgrestest: begin test transaction:", err)
+	}
+	if _, err := tx.Exec("SET LOCAL search_path TO " + schema + ", public;"); err != nil {
+		tb.Fatal("postgrestest: begin test transaction:", err)
+	}
+	return tx
+}
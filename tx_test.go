@@ -0,0 +1,100 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithSavepoint(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	db, err := srv.NewDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE foo (id INTEGER PRIMARY KEY);`); err != nil {
+		t.Fatal(err)
+	}
+
+	err = srv.WithSavepoint(ctx, tx, func() error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO foo VALUES (1);`)
+		return err
+	})
+	if err != nil {
+		t.Error("WithSavepoint with successful function:", err)
+	}
+	errFail := errors.New("bork")
+	err = srv.WithSavepoint(ctx, tx, func() error {
+		// Abort the transaction by violating the primary key.
+		tx.ExecContext(ctx, `INSERT INTO foo VALUES (2), (2);`)
+		return errFail
+	})
+	if !errors.Is(err, errFail) {
+		t.Errorf("WithSavepoint with failing function = %v; want %v", err, errFail)
+	}
+
+	var n int
+	if err := tx.QueryRowContext(ctx, `SELECT count(*) FROM foo;`).Scan(&n); err != nil {
+		t.Fatal("Transaction unusable after WithSavepoint:", err)
+	}
+	if n != 0 {
+		t.Errorf("foo has %d rows after WithSavepoint; want 0", n)
+	}
+}
+
+func TestTestTx(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithDatabases("shared"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	for i := 0; i < 2; i++ {
+		// Each run creates the same table, which would fail
+		// if the previous run's changes were visible.
+		t.Run("Run", func(t *testing.T) {
+			tx := srv.TestTx(t, "shared")
+			if _, err := tx.ExecContext(ctx, `CREATE TABLE foo (id INTEGER PRIMARY KEY);`); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO foo VALUES (1);`); err != nil {
+				t.Fatal(err)
+			}
+			var isSuper bool
+			if err := tx.QueryRowContext(ctx, `SELECT rolsuper FROM pg_roles WHERE rolname = current_user;`).Scan(&isSuper); err != nil {
+				t.Fatal(err)
+			}
+			if isSuper {
+				t.Error("current_user is a superuser; want the test's own role")
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// WaitForRowCount polls the database with the given data source name
+// until query, which must return a single integer like SELECT count(*),
+// returns want or ctx is done. It is intended for tests of asynchronous
+// writers, in place of sleeping. If ctx is done first,
+// the error includes the last count observed.
+func (srv *Server) WaitForRowCount(ctx context.Context, dsn, query string, want int) error {
+	db, err := sql.Open(srv.cfg.driverName, dsn)
+	if err != nil {
+		return fmt.Errorf("wait for row count: %w", err)
+	}
+	defer db.Close()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	last := -1
+	for {
+		var n int
+		err := db.QueryRowContext(ctx, query).Scan(&n)
+		switch {
+		case err == nil:
+			if n == want {
+				return nil
+			}
+			last = n
+		case ctx.Err() == nil:
+			return fmt.Errorf("wait for row count: %w", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if last < 0 {
+				return fmt.Errorf("wait for row count: %w", ctx.Err())
+			}
+			return fmt.Errorf("wait for row count: got %d, want %d: %w", last, want, ctx.Err())
+		}
+	}
+}
+
+// WaitForAutovacuum polls the database with the given data source name
+// until the autovacuum daemon has vacuumed or analyzed the given table
+// since WaitForAutovacuum was called, or ctx is done.
+// It is intended for tests of autovacuum's effects, in place of sleeping.
+// The server must run autovacuum (see WithAutovacuum),
+// and the table must have enough changed rows to need it.
+// The daemon checks each database every autovacuum_naptime,
+// which WithAutovacuumNaptime can shorten from the default of one minute.
+func (srv *Server) WaitForAutovacuum(ctx context.Context, dsn, table string) error {
+	db, err := sql.Open(srv.cfg.driverName, dsn)
+	if err != nil {
+		return fmt.Errorf("wait for autovacuum of %s: %w", table, err)
+	}
+	defer db.Close()
+	const query = "SELECT autovacuum_count + autoanalyze_count FROM pg_stat_user_tables WHERE relid = $1::regclass;"
+	var baseline int64
+	if err := db.QueryRowContext(ctx, query, table).Scan(&baseline); err != nil {
+		return fmt.Errorf("wait for autovacuum of %s: %w", table, err)
+	}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("wait for autovacuum of %s: %w", table, ctx.Err())
+		}
+		var n int64
+		err := db.QueryRowContext(ctx, query, table).Scan(&n)
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("wait for autovacuum of %s: %w", table, err)
+		}
+		if err == nil && n > baseline {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2026 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgrestest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForRowCount(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := srv.openDB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `CREATE TABLE jobs (id SERIAL PRIMARY KEY);`); err != nil {
+		t.Fatal(err)
+	}
+
+	insertErr := make(chan error, 1)
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(20 * time.Millisecond)
+			if _, err := db.ExecContext(ctx, `INSERT INTO jobs DEFAULT VALUES;`); err != nil {
+				insertErr <- err
+				return
+			}
+		}
+		insertErr <- nil
+	}()
+	if err := srv.WaitForRowCount(ctx, dsn, `SELECT count(*) FROM jobs;`, 3); err != nil {
+		t.Error(err)
+	}
+	if err := <-insertErr; err != nil {
+		t.Fatal(err)
+	}
+
+	shortCtx, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer shortCancel()
+	err = srv.WaitForRowCount(shortCtx, dsn, `SELECT count(*) FROM jobs;`, 4)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForRowCount for unreachable count = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if err != nil && !strings.Contains(err.Error(), "got 3") {
+		t.Errorf("WaitForRowCount error %q does not include last count", err)
+	}
+}
+
+func TestWaitForAutovacuum(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), singleTestTime)
+	defer cancel()
+	srv, err := Start(ctx, WithAutovacuum(true), WithAutovacuumNaptime(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Cleanup)
+	dsn, err := srv.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := srv.openDB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.ExecContext(ctx, `CREATE TABLE foo (id INTEGER) WITH (`+
+		`autovacuum_vacuum_threshold = 1, autovacuum_vacuum_scale_factor = 0, `+
+		`autovacuum_analyze_threshold = 1, autovacuum_analyze_scale_factor = 0);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- srv.WaitForAutovacuum(ctx, dsn, "foo")
+	}()
+	if _, err := db.ExecContext(ctx, `INSERT INTO foo SELECT i FROM generate_series(1, 100) i;`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM foo WHERE id % 2 = 0;`); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-waitErr; err != nil {
+		t.Error(err)
+	}
+}